@@ -0,0 +1,350 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultActionTTL   = 3 * 24 * time.Hour
+	minActionSecretLen = 16
+	actionSnooze       = "1d"
+)
+
+var (
+	errBadActionToken = errors.New("bad action link")
+	errActionExpired  = errors.New("action link expired")
+	errActionUsed     = errors.New("action link was already used")
+	errTaskChanged    = errors.New("task was changed")
+)
+
+// defaultUsedActionsFile returns file inside user's cache dir or empty string
+// if there is no cache dir.
+func defaultUsedActionsFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "md-tasks-notify", "used-actions.json")
+}
+
+// actionToken is a payload of signed action link.
+type actionToken struct {
+	Action  string `json:"a"`           // "done" or "snooze".
+	Snooze  string `json:"s,omitempty"` // Duration for "snooze", see parseSnooze.
+	File    string `json:"f"`
+	Line    int    `json:"l"`
+	Hash    string `json:"h"` // Hash of the task line, to not change another task if file was changed.
+	Expires int64  `json:"e"` // Unix time.
+	Nonce   string `json:"n"`
+}
+
+// Actions creates and handles signed, expiring, single-use links which change tasks.
+type Actions struct {
+	URL      string // Base URL of serve command, needed only to create links.
+	Secret   []byte
+	TTL      time.Duration
+	UsedFile string           // File to remember used links, empty to remember in memory only.
+	Tasks    tasksConfig      // Settings used to find changed tasks in files.
+	Now      func() time.Time // For testing.
+
+	mu   sync.Mutex
+	used map[string]int64 // Nonce -> expires.
+}
+
+// NewActionsFromEnv returns Actions configured by environment variables
+// ACTION_SECRET, ACTION_URL and ACTION_TTL or nil if ACTION_SECRET is not set.
+func NewActionsFromEnv() (*Actions, error) {
+	secret := os.Getenv("ACTION_SECRET")
+	if secret == "" {
+		return nil, nil //nolint:nilnil // Actions are optional.
+	}
+	if len(secret) < minActionSecretLen {
+		return nil, fmt.Errorf("ACTION_SECRET must be at least %d characters", minActionSecretLen)
+	}
+	a := &Actions{
+		URL:    strings.TrimSuffix(os.Getenv("ACTION_URL"), "/"),
+		Secret: []byte(secret),
+		TTL:    defaultActionTTL,
+		Now:    time.Now,
+	}
+	if ttl := os.Getenv("ACTION_TTL"); ttl != "" {
+		var err error
+		a.TTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("bad ACTION_TTL: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// Links returns Markdown links with actions for the task at given line of the file.
+// It returns empty string if a is nil, has no URL or file is unknown (stdin).
+func (a *Actions) Links(file string, task Task, line int) string {
+	if a == nil || a.URL == "" || file == "" {
+		return ""
+	}
+	done, snooze := a.Tokens(file, task, line)
+	open := url.URL{Scheme: "obsidian", Host: "open", RawQuery: url.Values{"path": {file}}.Encode()}
+	return fmt.Sprintf("[Done](%s) · [Snooze %s](%s) · [Open](%s)",
+		a.link(done), actionSnooze, a.link(snooze), open.String())
+}
+
+// Tokens returns signed tokens for "done" and "snooze" actions for the task at given line of the file.
+func (a *Actions) Tokens(file string, task Task, line int) (done, snooze string) {
+	tok := actionToken{
+		File:    file,
+		Line:    line,
+		Hash:    taskHash(task),
+		Expires: a.Now().Add(a.TTL).Unix(),
+	}
+	doneTok, snoozeTok := tok, tok
+	doneTok.Action = "done"
+	snoozeTok.Action, snoozeTok.Snooze = "snooze", actionSnooze
+	return a.sign(doneTok), a.sign(snoozeTok)
+}
+
+func (a *Actions) link(token string) string {
+	return a.URL + "/action?" + url.Values{"t": {token}}.Encode()
+}
+
+func (a *Actions) sign(tok actionToken) string {
+	nonce := make([]byte, 16) //nolint:mnd // 128 bits.
+	_, _ = rand.Read(nonce)
+	tok.Nonce = hex.EncodeToString(nonce)
+	payload, err := json.Marshal(tok)
+	if err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns payload of not expired token with valid signature.
+func (a *Actions) verify(token string) (*actionToken, error) {
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errBadActionToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, errBadActionToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, errBadActionToken
+	}
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errBadActionToken
+	}
+	var tok actionToken
+	err = json.Unmarshal(payload, &tok)
+	if err != nil || tok.Nonce == "" {
+		return nil, errBadActionToken
+	}
+	if a.Now().Unix() >= tok.Expires {
+		return nil, errActionExpired
+	}
+	return &tok, nil
+}
+
+// check returns task referenced by token if token can be applied.
+func (a *Actions) check(token string) (*actionToken, Task, error) {
+	tok, err := a.verify(token)
+	if err != nil {
+		return nil, Task{}, err
+	}
+	err = a.loadUsed()
+	if err != nil {
+		return nil, Task{}, err
+	}
+	if _, ok := a.used[tok.Nonce]; ok {
+		return nil, Task{}, errActionUsed
+	}
+	data, err := os.ReadFile(tok.File)
+	if err != nil {
+		return nil, Task{}, err
+	}
+	task, err := openTaskAt(&a.Tasks, data, tok.Line)
+	if err != nil {
+		return nil, Task{}, fmt.Errorf("%w: %w", errTaskChanged, err)
+	}
+	if taskHash(task) != tok.Hash {
+		return nil, Task{}, errTaskChanged
+	}
+	return tok, task, nil
+}
+
+// Apply changes the task as described by token and returns changed task line.
+func (a *Actions) Apply(token string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tok, task, err := a.check(token)
+	if err != nil {
+		return "", err
+	}
+	today := dateOf(a.Now())
+	var changed string
+	switch tok.Action {
+	case "done":
+		changed, err = completeTaskInFile(&a.Tasks, tok.File, tok.Line, today)
+	case "snooze":
+		var add func(time.Time) time.Time
+		add, err = parseSnooze(tok.Snooze)
+		if err == nil {
+			changed, err = snoozeTaskInFile(&a.Tasks, tok.File, tok.Line, add, today, editOptions{}, io.Discard)
+		}
+	default:
+		err = errBadActionToken
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Action %s: %s:%d: %s", tok.Action, tok.File, tok.Line, task.Line)
+
+	a.used[tok.Nonce] = tok.Expires
+	err = a.saveUsed()
+	if err != nil {
+		log.Println("Warning: Failed to save used actions:", err)
+	}
+	return changed, nil
+}
+
+func (a *Actions) loadUsed() error {
+	if a.used != nil {
+		return nil
+	}
+	used := make(map[string]int64)
+	if a.UsedFile != "" {
+		data, err := os.ReadFile(a.UsedFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load used actions: %w", err)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &used)
+			if err != nil {
+				return fmt.Errorf("load used actions %q: %w", a.UsedFile, err)
+			}
+		}
+	}
+	a.used = used
+	return nil
+}
+
+func (a *Actions) saveUsed() error {
+	now := a.Now().Unix()
+	for nonce, expires := range a.used {
+		if expires <= now {
+			delete(a.used, nonce)
+		}
+	}
+	if a.UsedFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(a.used)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(a.UsedFile), 0o700)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(a.UsedFile), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Will fail after successful rename.
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), a.UsedFile)
+}
+
+// taskHash returns short hash of the task line.
+func taskHash(task Task) string {
+	sum := sha256.Sum256([]byte(strings.TrimRight(task.Line, " \t\r\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+var actionPage = template.Must(template.New("action").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width">
+<title>md-tasks-notify</title></head>
+<body>
+{{- if .Error}}<p>{{.Error}}</p>
+{{- else if .Changed}}<p>{{.Changed}}</p>
+{{- else}}<p>{{.Task}}</p>
+<form method="post"><input type="hidden" name="t" value="{{.Token}}">
+<button type="submit">{{if eq .Action "done"}}Done{{else}}Snooze {{.Snooze}}{{end}}</button></form>
+{{- end}}
+</body></html>
+`))
+
+// ServeHTTP shows confirmation page for GET (to not apply actions when links are
+// prefetched by mail scanners) and applies the action for POST.
+func (a *Actions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("t")
+	var data struct {
+		Error, Task, Changed, Token, Action, Snooze string
+	}
+	status := http.StatusOK
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		a.mu.Lock()
+		var tok *actionToken
+		var task Task
+		tok, task, err = a.check(token)
+		a.mu.Unlock()
+		if err == nil {
+			data.Task, data.Token, data.Action, data.Snooze = task.Line, token, tok.Action, tok.Snooze
+		}
+	case http.MethodPost:
+		data.Changed, err = a.Apply(token)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		data.Error = err.Error()
+		switch {
+		case errors.Is(err, errBadActionToken):
+			status = http.StatusBadRequest
+		case errors.Is(err, errActionExpired), errors.Is(err, errActionUsed):
+			status = http.StatusGone
+		case errors.Is(err, errTaskChanged), errors.Is(err, errConcurrentModification):
+			status = http.StatusConflict
+		default:
+			log.Println("Warning: Failed to apply action:", err)
+			status = http.StatusInternalServerError
+			data.Error = "failed to apply action"
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = actionPage.Execute(w, data)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	err := os.WriteFile(path, []byte("# Tasks\n\n- [ ] Task 📅 2024-01-15\n- [ ] Other 📅 2024-01-15\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	a := &Actions{
+		URL:      "https://example.com",
+		Secret:   []byte("0123456789abcdef"),
+		TTL:      time.Hour,
+		UsedFile: filepath.Join(dir, "cache", "used.json"),
+		Now:      func() time.Time { return now },
+	}
+
+	var buf bytes.Buffer
+	err = filterActualTasks(&filterOptions{Now: now, Actions: a}, nil, path, mustReadFile(t, path), &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`^- \[ \] Task 📅 2024-01-15\n` +
+		`  \[Done\]\((\S+)\) · \[Snooze 1d\]\((\S+)\) · \[Open\]\(obsidian://open\?path=\S+\)\n` +
+		`- \[ \] Other`).FindStringSubmatch(buf.String())
+	if m == nil {
+		t.Fatalf("no action links in:\n%s", buf.String())
+	}
+	token := func(link string) string {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Query().Get("t")
+	}
+	done, snooze := token(m[1]), token(m[2])
+
+	do := func(method, token string) (int, string) {
+		t.Helper()
+		form := url.Values{"t": {token}}
+		var req *http.Request
+		if method == http.MethodGet {
+			req = httptest.NewRequest(method, "/action?"+form.Encode(), nil)
+		} else {
+			req = httptest.NewRequest(method, "/action", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := do(http.MethodGet, done)
+	if code != http.StatusOK || !strings.Contains(body, `<form method="post">`) {
+		t.Errorf("GET = %d %s", code, body)
+	}
+	if string(mustReadFile(t, path)) != "# Tasks\n\n- [ ] Task 📅 2024-01-15\n- [ ] Other 📅 2024-01-15\n" {
+		t.Errorf("file changed by GET")
+	}
+
+	code, body = do(http.MethodGet, done[:len(done)-2]+"xx")
+	if code != http.StatusBadRequest {
+		t.Errorf("GET with bad signature = %d %s", code, body)
+	}
+
+	code, body = do(http.MethodPost, done)
+	if code != http.StatusOK || !strings.Contains(body, "[x] Task 📅 2024-01-15 ✅ 2024-01-15") {
+		t.Errorf("POST done = %d %s", code, body)
+	}
+	code, body = do(http.MethodPost, done)
+	if code != http.StatusGone {
+		t.Errorf("POST done again = %d %s", code, body)
+	}
+	code, body = do(http.MethodPost, snooze)
+	if code != http.StatusConflict {
+		t.Errorf("POST snooze of done task = %d %s", code, body)
+	}
+
+	// Used links are remembered after restart.
+	a2 := &Actions{Secret: a.Secret, TTL: a.TTL, UsedFile: a.UsedFile, Now: a.Now}
+	_, err = a2.Apply(done)
+	if !errors.Is(err, errActionUsed) {
+		t.Errorf("Apply() after restart error = %v, want %v", err, errActionUsed)
+	}
+
+	now = now.Add(2 * time.Hour)
+	_, err = a.Apply(snooze)
+	if !errors.Is(err, errActionExpired) {
+		t.Errorf("Apply() expired error = %v, want %v", err, errActionExpired)
+	}
+}
+
+func TestActionsSnooze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.md")
+	err := os.WriteFile(path, []byte("- [ ] Task ⏳ 2024-01-15\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	a := &Actions{URL: "http://localhost", Secret: []byte("secret"), TTL: time.Hour, Now: func() time.Time { return now }}
+	tok := a.sign(actionToken{
+		Action: "snooze", Snooze: "1d", File: path, Line: 1,
+		Hash: taskHash(Task{Line: "[ ] Task ⏳ 2024-01-15"}), Expires: now.Add(time.Hour).Unix(),
+	})
+
+	changed, err := a.Apply(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[ ] Task ⏳ 2024-01-16"; changed != want {
+		t.Errorf("Apply() = %q, want %q", changed, want)
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
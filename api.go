@@ -0,0 +1,246 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/powerman/goldmark-obsidian/obsast"
+)
+
+var errBadAPIParam = errors.New("bad parameter")
+
+// apiTask is a task in JSON API.
+type apiTask struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line,omitempty"` // Missing for upcoming occurrences.
+	Text       string   `json:"text"`
+	Status     string   `json:"status"` // todo or in_progress.
+	Priority   string   `json:"priority"`
+	Due        string   `json:"due,omitempty"`
+	Scheduled  string   `json:"scheduled,omitempty"`
+	Start      string   `json:"start,omitempty"`
+	Recurrence string   `json:"recurrence,omitempty"`
+	ID         string   `json:"id,omitempty"`
+	DependsOn  []string `json:"depends_on,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Upcoming   bool     `json:"upcoming,omitempty"` // Upcoming occurrence of recurring task.
+}
+
+// apiFile is a Markdown file in JSON API.
+type apiFile struct {
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// apiTag is a tag in JSON API.
+type apiTag struct {
+	Tag   string `json:"tag"`
+	Tasks int    `json:"tasks"` // Amount of not done tasks with this tag.
+}
+
+func newAPITask(t dashboardTask) apiTask {
+	date := func(d time.Time) string {
+		if d.IsZero() {
+			return ""
+		}
+		return d.Format(time.DateOnly)
+	}
+	status := "todo"
+	if t.Task.StatusType == obsast.PlugTasksStatusTypeInProgress {
+		status = "in_progress"
+	}
+	return apiTask{
+		File:       t.File,
+		Line:       t.Line,
+		Text:       t.Text,
+		Status:     status,
+		Priority:   priorities[t.Priority].Name,
+		Due:        date(t.Task.Due),
+		Scheduled:  date(t.Task.Scheduled),
+		Start:      date(t.Task.Start),
+		Recurrence: t.Task.Recurrence,
+		ID:         t.Task.ID,
+		DependsOn:  t.Task.DependsOn,
+		Tags:       t.Tags,
+		Upcoming:   t.Line == 0,
+	}
+}
+
+// parseAPIDay parses day relative to today (like -1) or date (YYYY-MM-DD).
+func parseAPIDay(name, value string, today time.Time, def int) (int, error) {
+	switch {
+	case value == "":
+		return def, nil
+	case strings.Count(value, "-") == 2: //nolint:mnd // YYYY-MM-DD.
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return 0, fmt.Errorf("%w %s: %w", errBadAPIParam, name, err)
+		}
+		return daysBetween(today, date), nil
+	default:
+		day, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("%w %s: %w", errBadAPIParam, name, err)
+		}
+		return day, nil
+	}
+}
+
+// ServeAPITasks returns JSON list of tasks which main command outputs, without blocked ones.
+//
+// Supported query parameters:
+//   - from, to: day relative to today (e.g. -1, 0, 7) or date YYYY-MM-DD
+//     (default is window of serve command, if only one is set then another defaults to it)
+//   - window: see parseWindow (used if from and to are not set)
+//   - query: space-separated words, all must be found in task text, words starting with # must be tags
+//   - upcoming: if not empty then also return upcoming occurrences of recurring tasks
+func (d *Dashboard) ServeAPITasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := d.Now()
+	d.serveJSON(w, r, dateOf(now).Format(time.DateOnly)+"\x00"+r.URL.RawQuery, func() (any, error) {
+		fromDay, toDay, err := parseWindow(cmp.Or(q.Get("window"), d.Window), now, d.WeekStart, nil)
+		if err != nil {
+			return nil, err
+		}
+		from, to := q.Get("from"), q.Get("to")
+		if from != "" || to != "" {
+			from, to = cmp.Or(from, to), cmp.Or(to, from)
+		}
+		fromDay, err = parseAPIDay("from", from, dateOf(now), fromDay)
+		if err != nil {
+			return nil, err
+		}
+		toDay, err = parseAPIDay("to", to, dateOf(now), toDay)
+		if err != nil {
+			return nil, err
+		}
+		if fromDay > toDay {
+			return nil, fmt.Errorf("%w: from is after to", errBadAPIParam)
+		}
+
+		tasks, err := d.collect(&filterOptions{
+			Now:             now,
+			FromDay:         fromDay,
+			ToDay:           toDay,
+			ExpandRecurring: q.Get("upcoming") != "",
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.SortStableFunc(tasks, func(a, b dashboardTask) int { return cmp.Compare(a.File, b.File) })
+		words := strings.Fields(strings.ToLower(q.Get("query")))
+		result := []apiTask{}
+		for _, t := range tasks {
+			if matchAPIQuery(t, words) {
+				result = append(result, newAPITask(t))
+			}
+		}
+		return result, nil
+	})
+}
+
+func matchAPIQuery(t dashboardTask, words []string) bool {
+	text := strings.ToLower(t.Text)
+	for _, word := range words {
+		if strings.HasPrefix(word, "#") {
+			if !slices.ContainsFunc(t.Tags, func(tag string) bool { return strings.EqualFold(tag, word) }) {
+				return false
+			}
+		} else if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeAPIFiles returns JSON list of Markdown files.
+func (d *Dashboard) ServeAPIFiles(w http.ResponseWriter, r *http.Request) {
+	d.serveJSON(w, r, "", func() (any, error) {
+		files, err := d.Config.Files.statMarkdownFiles(d.Paths)
+		if err != nil {
+			return nil, err
+		}
+		result := []apiFile{}
+		for _, name := range slices.Sorted(maps.Keys(files)) {
+			result = append(result, apiFile{File: name, Size: files[name].Size(), Modified: files[name].ModTime()})
+		}
+		return result, nil
+	})
+}
+
+// ServeAPITags returns JSON list of tags used by not done tasks.
+func (d *Dashboard) ServeAPITags(w http.ResponseWriter, r *http.Request) {
+	d.serveJSON(w, r, "", func() (any, error) {
+		files, err := parseMarkdownPaths(&d.Config, d.Paths, d.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		counts := make(map[string]int)
+		for _, file := range files {
+			if file.Settings.Skip {
+				continue
+			}
+			for _, t := range file.Tasks {
+				if isOpen(t.Task) && (!t.Task.Due.IsZero() || !t.Task.Scheduled.IsZero()) {
+					for _, tag := range taskTags(t.Task, file.Settings) {
+						counts[tag]++
+					}
+				}
+			}
+		}
+		result := []apiTag{}
+		for _, tag := range slices.Sorted(maps.Keys(counts)) {
+			result = append(result, apiTag{Tag: tag, Tasks: counts[tag]})
+		}
+		return result, nil
+	})
+}
+
+// serveJSON responds with JSON returned by get, with support for ETag based on
+// modification times of Markdown files and key (which must include all other inputs of get).
+func (d *Dashboard) serveJSON(w http.ResponseWriter, r *http.Request, key string, get func() (any, error)) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	sum := sha256.Sum256([]byte(d.version() + "\x00" + r.URL.Path + "\x00" + key))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for tag := range strings.SplitSeq(match, ",") {
+			if tag = strings.TrimSpace(tag); tag == etag || tag == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	v, err := get()
+	switch {
+	case errors.Is(err, errBadWindow), errors.Is(err, errBadAPIParam):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println("Warning: Failed to send response:", err)
+	}
+}
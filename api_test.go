@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.md"), []byte(`- [ ] Low #work 🔽 📅 2024-01-15
+- [/] High #work #home ⏫ ⏳ 2024-01-14 📅 2024-01-15
+- [ ] Tomorrow 📅 2024-01-16
+- [x] Done #work 📅 2024-01-15
+- [ ] Someday #work
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	d := &Dashboard{Paths: []string{dir}, Window: "today", Now: func() time.Time { return now }}
+	mux := newServeMux(d)
+	file := filepath.Join(dir, "a.md")
+
+	get := func(t *testing.T, target, etag string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		want       []apiTask
+	}{
+		{
+			target:     "/api/tasks",
+			wantStatus: http.StatusOK,
+			want: []apiTask{
+				{
+					File: file, Line: 1, Text: "[ ] Low #work 🔽 📅 2024-01-15", Status: "todo", Priority: "low",
+					Due: "2024-01-15", Tags: []string{"#work"},
+				},
+				{
+					File: file, Line: 2, Text: "[/] High #work #home ⏫ ⏳ 2024-01-14 📅 2024-01-15", Status: "in_progress",
+					Priority: "high", Due: "2024-01-15", Scheduled: "2024-01-14", Tags: []string{"#work", "#home"},
+				},
+			},
+		},
+		{
+			target:     "/api/tasks?from=1&to=2024-01-16",
+			wantStatus: http.StatusOK,
+			want: []apiTask{
+				{File: file, Line: 3, Text: "[ ] Tomorrow 📅 2024-01-16", Status: "todo", Priority: "normal", Due: "2024-01-16"},
+			},
+		},
+		{
+			target:     "/api/tasks?query=%23home+HIGH",
+			wantStatus: http.StatusOK,
+			want: []apiTask{
+				{
+					File: file, Line: 2, Text: "[/] High #work #home ⏫ ⏳ 2024-01-14 📅 2024-01-15", Status: "in_progress",
+					Priority: "high", Due: "2024-01-15", Scheduled: "2024-01-14", Tags: []string{"#work", "#home"},
+				},
+			},
+		},
+		{
+			target:     "/api/tasks?query=%23home+low",
+			wantStatus: http.StatusOK,
+			want:       []apiTask{},
+		},
+		{target: "/api/tasks?from=x", wantStatus: http.StatusBadRequest},
+		{target: "/api/tasks?from=2&to=1", wantStatus: http.StatusBadRequest},
+		{target: "/api/tasks?window=bad", wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			w := get(t, tc.target, "")
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got []apiTask
+			err := json.Unmarshal(w.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tc.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("tasks:\n got %s\nwant %s", gotJSON, wantJSON)
+			}
+		})
+	}
+
+	t.Run("tags", func(t *testing.T) {
+		w := get(t, "/api/tags", "")
+		want := `[{"tag":"#home","tasks":1},{"tag":"#work","tasks":2}]` + "\n"
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("got %d %s, want %s", w.Code, w.Body, want)
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		w := get(t, "/api/files", "")
+		var got []apiFile
+		err := json.Unmarshal(w.Body.Bytes(), &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].File != file || got[0].Size == 0 {
+			t.Errorf("files = %+v", got)
+		}
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		etag := get(t, "/api/tasks", "").Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("no ETag")
+		}
+		if w := get(t, "/api/tasks", etag); w.Code != http.StatusNotModified {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNotModified)
+		}
+		if w := get(t, "/api/tasks?from=1", etag); w.Code != http.StatusOK {
+			t.Errorf("other query: status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if w := get(t, "/api/tags", etag); w.Code != http.StatusOK {
+			t.Errorf("other endpoint: status = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		later := now.Add(time.Hour)
+		err := os.Chtimes(file, later, later)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := get(t, "/api/tasks", etag); w.Code != http.StatusOK {
+			t.Errorf("after change: status = %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+}
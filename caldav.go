@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/powerman/goldmark-obsidian/obsast"
+)
+
+const (
+	caldavPath       = "/caldav/"            // Principal and calendar home.
+	caldavCollection = caldavPath + "tasks/" // The only calendar.
+	caldavMaxBody    = 1 << 20               // Limit size of request body.
+	caldavCompType   = "text/calendar; charset=utf-8; component=vtodo"
+	nsDAV            = "DAV:"
+	nsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+var (
+	errBadDAVRequest = errors.New("bad request body")
+	errBadVTODO      = errors.New("bad VTODO")
+
+	// Prefixes used for known namespaces in responses.
+	davPrefixes = map[string]string{nsDAV: "d", nsCalDAV: "c", nsCalendarServer: "cs"}
+)
+
+// CalDAV is a CalDAV server (subset of RFC 4791) with a single calendar
+// containing not done tasks from Paths as VTODO.
+//
+// Clients may mark tasks done, cancelled or in progress and change their
+// due date, changes are written back to the task lines.
+// Creating and deleting tasks is not supported.
+type CalDAV struct {
+	Paths       []string
+	Config      config // Files and tasks to serve.
+	MaxFileSize int64  // If > 0, larger files are skipped.
+	Username    string // If empty then any user name is accepted.
+	Password    string
+	Now         func() time.Time // For testing.
+
+	mu sync.Mutex // Serializes changes.
+}
+
+// NewCalDAVFromEnv returns CalDAV for paths, cfg and maxFileSize configured by environment
+// variables CALDAV_USER and CALDAV_PASSWORD or nil if CALDAV_PASSWORD is not set.
+func NewCalDAVFromEnv(paths []string, cfg config, maxFileSize int64) *CalDAV {
+	password := os.Getenv("CALDAV_PASSWORD")
+	if password == "" {
+		return nil
+	}
+	return &CalDAV{
+		Paths:       paths,
+		Config:      cfg,
+		MaxFileSize: maxFileSize,
+		Username:    os.Getenv("CALDAV_USER"),
+		Password:    password,
+		Now:         time.Now,
+	}
+}
+
+// ServeHTTP handles CalDAV requests at caldavPath.
+func (c *CalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(c.Password)) != 1 ||
+		(c.Username != "" && subtle.ConstantTimeCompare([]byte(user), []byte(c.Username)) != 1) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="md-tasks-notify", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, PROPFIND, REPORT")
+	case "PROPFIND":
+		c.propfind(w, r)
+	case "REPORT":
+		c.report(w, r)
+	case http.MethodGet, http.MethodHead:
+		c.get(w, r)
+	case http.MethodPut:
+		c.put(w, r)
+	case http.MethodDelete:
+		http.Error(w, "deleting tasks is not supported", http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// tasks returns all not done tasks. Unlike parseFiles it parses every file,
+// because tasks without dates are served too.
+func (c *CalDAV) tasks() ([]icsTask, error) {
+	filenames, err := c.Config.Files.listMarkdownFiles(c.Paths)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]parsedFile, len(filenames))
+	md := newMarkdown()
+	for _, filename := range filenames {
+		data, err := readMarkdownFile(filename, c.MaxFileSize)
+		if errors.Is(err, errFileTooLarge) {
+			log.Println("Warning: Skipping", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[filename], err = parseFileTasks(md, &c.Config.Tasks, filename, data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %w", filename, err)
+		}
+	}
+	return collectICSTasks(files, func(task Task, _ noteSettings) bool { return isOpen(task) }), nil
+}
+
+func (c *CalDAV) propfind(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDAVRequest(io.LimitReader(r.Body, caldavMaxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tasks, err := c.tasks()
+	if err != nil {
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	depth0 := r.Header.Get("Depth") == "0"
+	var responses []davResponse
+	switch path := strings.TrimSuffix(r.URL.Path, "/") + "/"; path {
+	case caldavPath:
+		responses = append(responses, c.rootResponse())
+		if !depth0 {
+			responses = append(responses, c.collectionResponse(tasks))
+		}
+	case caldavCollection:
+		responses = append(responses, c.collectionResponse(tasks))
+		if !depth0 {
+			for _, t := range tasks {
+				responses = append(responses, c.taskResponse(t))
+			}
+		}
+	default:
+		t, ok := findCalDAVTask(tasks, r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, c.taskResponse(t))
+	}
+	writeMultistatus(w, responses, req.Props)
+}
+
+func (c *CalDAV) report(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDAVRequest(io.LimitReader(r.Body, caldavMaxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tasks, err := c.tasks()
+	if err != nil {
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	responses := []davResponse{}
+	switch req.Root {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		// Only filter by component is supported, other filters match all tasks.
+		if slices.ContainsFunc(req.Comps, func(name string) bool { return name != "VCALENDAR" && name != "VTODO" }) {
+			break
+		}
+		for _, t := range tasks {
+			responses = append(responses, c.taskResponse(t))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			u, err := url.Parse(href)
+			if err != nil {
+				responses = append(responses, davResponse{Href: href, Status: http.StatusNotFound})
+				continue
+			}
+			if t, ok := findCalDAVTask(tasks, u.Path); ok {
+				responses = append(responses, c.taskResponse(t))
+			} else {
+				responses = append(responses, davResponse{Href: u.Path, Status: http.StatusNotFound})
+			}
+		}
+	default:
+		http.Error(w, "unsupported report", http.StatusForbidden)
+		return
+	}
+	writeMultistatus(w, responses, req.Props)
+}
+
+func (c *CalDAV) get(w http.ResponseWriter, r *http.Request) {
+	tasks, err := c.tasks()
+	if err != nil {
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	t, ok := findCalDAVTask(tasks, r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", caldavCompType)
+	w.Header().Set("ETag", caldavETag(t))
+	err = writeICS(w, "VTODO", c.Now(), []icsTask{t})
+	if err != nil {
+		log.Println("Warning: Failed to send response:", err)
+	}
+}
+
+func (c *CalDAV) put(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tasks, err := c.tasks()
+	if err != nil {
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	t, ok := findCalDAVTask(tasks, r.URL.Path)
+	switch {
+	case !ok:
+		http.Error(w, "creating tasks is not supported", http.StatusForbidden)
+		return
+	case r.Header.Get("If-None-Match") == "*",
+		r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != "*" && r.Header.Get("If-Match") != caldavETag(t):
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, caldavMaxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	todo, err := parseVTODO(body, c.Now().Location())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.update(t, todo)
+	switch {
+	case errors.Is(err, errTaskChanged), errors.Is(err, errConcurrentModification), errors.Is(err, errEditConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Println("Warning: Failed to change task:", err)
+		http.Error(w, "failed to change task", http.StatusInternalServerError)
+		return
+	}
+	// No ETag because stored task differs from the one sent by client (RFC 4791 section 5.3.4).
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// update changes the task to match todo sent by client.
+func (c *CalDAV) update(t icsTask, todo *vtodo) error {
+	today := dateOf(c.Now())
+	current := func(source []byte) (Task, error) {
+		task, err := openTaskAt(&c.Config.Tasks, source, t.Line)
+		if err != nil {
+			return Task{}, fmt.Errorf("%w: %w", errTaskChanged, err)
+		}
+		if taskHash(task) != taskHash(t.Task) {
+			return Task{}, errTaskChanged
+		}
+		return task, nil
+	}
+
+	if todo.Status == "COMPLETED" || todo.Completed {
+		data, err := os.ReadFile(t.File)
+		if err != nil {
+			return err
+		}
+		_, err = current(data)
+		if err != nil {
+			return err
+		}
+		result, changed, err := completeTask(&c.Config.Tasks, data, t.Line, today)
+		if err != nil {
+			return err
+		}
+		err = replaceFile(t.File, data, result, false)
+		if err != nil {
+			return err
+		}
+		log.Printf("CalDAV done: %s:%d: %s", t.File, t.Line, changed)
+		return nil
+	}
+
+	return editFile(t.File, editOptions{}, io.Discard, func(source []byte) ([]taskEdit, error) {
+		task, err := current(source)
+		if err != nil {
+			return nil, err
+		}
+		old := strings.TrimRight(task.Line, " \t\r\n")
+		line := old
+		switch {
+		case todo.Status == "IN-PROCESS" && task.StatusType != obsast.PlugTasksStatusTypeInProgress:
+			line = setTaskStatus(line, "/")
+		case todo.Status == "NEEDS-ACTION" && task.StatusType != obsast.PlugTasksStatusTypeTODO:
+			line = setTaskStatus(line, " ")
+		case todo.Status == "CANCELLED":
+			line = setTaskDate(setTaskStatus(line, "-"), "❌", today)
+		}
+		emoji, due := "📅", task.Due // Same date as in writeICS.
+		if due.IsZero() && !task.Scheduled.IsZero() {
+			emoji, due = "⏳", task.Scheduled
+		}
+		if !todo.Due.IsZero() && !todo.Due.Equal(due) {
+			line = setTaskDate(line, emoji, todo.Due)
+		}
+		if line == old {
+			return nil, nil
+		}
+		log.Printf("CalDAV change: %s:%d: %s", t.File, t.Line, line)
+		return []taskEdit{{Offset: task.Offset, Old: old, New: line}}, nil
+	})
+}
+
+// setTaskStatus returns task line with status symbol in checkbox replaced by symbol.
+func setTaskStatus(line, symbol string) string {
+	m := reTaskCheckbox.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+	return line[:m[2]] + symbol + line[m[3]:]
+}
+
+func (c *CalDAV) rootResponse() davResponse {
+	href := "<d:href>" + caldavPath + "</d:href>"
+	return davResponse{Href: caldavPath, Props: map[xml.Name]string{
+		davName("resourcetype"):           "<d:collection/><d:principal/>",
+		davName("displayname"):            "md-tasks-notify",
+		davName("current-user-principal"): href,
+		davName("principal-URL"):          href,
+		calDAVName("calendar-home-set"):   href,
+	}}
+}
+
+func (c *CalDAV) collectionResponse(tasks []icsTask) davResponse {
+	h := sha256.New()
+	for _, t := range tasks {
+		_, _ = fmt.Fprintf(h, "%s\x00%s\x00", t.UID, caldavETag(t))
+	}
+	ctag := hex.EncodeToString(h.Sum(nil)[:16])
+	return davResponse{Href: caldavCollection, Props: map[xml.Name]string{
+		davName("resourcetype"):           "<d:collection/><c:calendar/>",
+		davName("displayname"):            "Tasks",
+		davName("getetag"):                xmlText(`"` + ctag + `"`),
+		davName("current-user-principal"): "<d:href>" + caldavPath + "</d:href>",
+		davName("current-user-privilege-set"): "<d:privilege><d:read/></d:privilege>" +
+			"<d:privilege><d:write-content/></d:privilege>",
+		calDAVName("supported-calendar-component-set"): `<c:comp name="VTODO"/>`,
+		{Space: nsCalendarServer, Local: "getctag"}:    ctag,
+	}}
+}
+
+func (c *CalDAV) taskResponse(t icsTask) davResponse {
+	var ics strings.Builder
+	_ = writeICS(&ics, "VTODO", c.Now(), []icsTask{t})
+	return davResponse{Href: caldavHref(t), Props: map[xml.Name]string{
+		davName("resourcetype"):     "",
+		davName("getetag"):          xmlText(caldavETag(t)),
+		davName("getcontenttype"):   caldavCompType,
+		calDAVName("calendar-data"): xmlText(ics.String()),
+	}}
+}
+
+func caldavHref(t icsTask) string {
+	return caldavCollection + url.PathEscape(t.UID) + ".ics"
+}
+
+func caldavETag(t icsTask) string {
+	return `"` + taskHash(t.Task) + `"`
+}
+
+func findCalDAVTask(tasks []icsTask, path string) (icsTask, bool) {
+	name, ok := strings.CutPrefix(path, caldavCollection)
+	if !ok {
+		return icsTask{}, false
+	}
+	name, ok = strings.CutSuffix(name, ".ics")
+	if !ok {
+		return icsTask{}, false
+	}
+	i := slices.IndexFunc(tasks, func(t icsTask) bool { return t.UID == name })
+	if i == -1 {
+		return icsTask{}, false
+	}
+	return tasks[i], true
+}
+
+func davName(local string) xml.Name    { return xml.Name{Space: nsDAV, Local: local} }
+func calDAVName(local string) xml.Name { return xml.Name{Space: nsCalDAV, Local: local} }
+
+func xmlText(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// davRequest is a body of PROPFIND or REPORT request.
+type davRequest struct {
+	Root  xml.Name   // Like propfind, calendar-query or calendar-multiget.
+	Props []xml.Name // Requested properties, nil for all.
+	Hrefs []string   // Resources requested by calendar-multiget.
+	Comps []string   // Names of components in comp-filter of calendar-query.
+}
+
+func parseDAVRequest(body io.Reader) (*davRequest, error) {
+	req := &davRequest{}
+	dec := xml.NewDecoder(body)
+	var stack []xml.Name
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errBadDAVRequest, err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case len(stack) == 0:
+				req.Root = tok.Name
+			case len(stack) == 2 && stack[1] == davName("prop"): //nolint:mnd // Child of prop.
+				req.Props = append(req.Props, tok.Name)
+			case tok.Name == calDAVName("comp-filter"):
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "name" {
+						req.Comps = append(req.Comps, strings.ToUpper(attr.Value))
+					}
+				}
+			}
+			stack = append(stack, tok.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 2 && stack[1] == davName("href") { //nolint:mnd // Child of root.
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(string(tok)))
+			}
+		}
+	}
+	return req, nil
+}
+
+// davResponse is a response element of multistatus.
+type davResponse struct {
+	Href   string
+	Status int                 // If not 0 then response has no properties.
+	Props  map[xml.Name]string // Available properties with their XML content.
+}
+
+// writeMultistatus writes responses with requested properties (all if props is nil).
+func writeMultistatus(w http.ResponseWriter, responses []davResponse, props []xml.Name) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCalendarServer + `">`)
+	for _, resp := range responses {
+		b.WriteString("<d:response><d:href>" + xmlText(resp.Href) + "</d:href>")
+		if resp.Status != 0 {
+			_, _ = fmt.Fprintf(&b, "<d:status>HTTP/1.1 %d %s</d:status>", resp.Status, http.StatusText(resp.Status))
+			b.WriteString("</d:response>")
+			continue
+		}
+		var found, missing []xml.Name
+		for name := range resp.Props {
+			if props == nil && name != calDAVName("calendar-data") {
+				found = append(found, name)
+			}
+		}
+		slices.SortFunc(found, func(a, b xml.Name) int { return strings.Compare(a.Space+a.Local, b.Space+b.Local) })
+		for _, name := range props {
+			if _, ok := resp.Props[name]; ok {
+				found = append(found, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		writePropstat(&b, resp.Props, found, http.StatusOK)
+		writePropstat(&b, nil, missing, http.StatusNotFound)
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>\n")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		log.Println("Warning: Failed to send response:", err)
+	}
+}
+
+func writePropstat(b *strings.Builder, values map[xml.Name]string, names []xml.Name, status int) {
+	if len(names) == 0 {
+		return
+	}
+	b.WriteString("<d:propstat><d:prop>")
+	for _, name := range names {
+		prefix, ok := davPrefixes[name.Space]
+		switch {
+		case ok:
+			_, _ = fmt.Fprintf(b, "<%s:%s>%s</%s:%s>", prefix, name.Local, values[name], prefix, name.Local)
+		case name.Space == "":
+			_, _ = fmt.Fprintf(b, "<%s/>", name.Local)
+		default:
+			_, _ = fmt.Fprintf(b, `<x:%s xmlns:x="%s"/>`, name.Local, xmlText(name.Space))
+		}
+	}
+	_, _ = fmt.Fprintf(b, "<d:status>HTTP/1.1 %d %s</d:status></d:propstat>", status, http.StatusText(status))
+}
+
+// vtodo contains properties of VTODO which may be changed by CalDAV clients.
+type vtodo struct {
+	Status    string // NEEDS-ACTION, IN-PROCESS, COMPLETED or CANCELLED.
+	Completed bool   // Has COMPLETED property.
+	Due       time.Time
+}
+
+// parseVTODO returns properties of the only VTODO in iCalendar data.
+// Due date with time in UTC is converted to date in loc.
+func parseVTODO(data []byte, loc *time.Location) (*vtodo, error) {
+	// Unfold long lines (RFC 5545 section 3.1).
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\n "), nil)
+	data = bytes.ReplaceAll(data, []byte("\n\t"), nil)
+
+	var todo *vtodo
+	depth := 0 // Inside VTODO: 1, inside its subcomponents (like VALARM): more.
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), ":")
+		name, _, _ = strings.Cut(name, ";") // Drop parameters like VALUE=DATE.
+		name = strings.ToUpper(name)
+		switch {
+		case name == "BEGIN" && depth > 0:
+			depth++
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			if todo != nil {
+				return nil, fmt.Errorf("%w: multiple VTODO", errBadVTODO)
+			}
+			todo, depth = &vtodo{}, 1
+		case name == "END" && depth > 0:
+			depth--
+		case depth != 1:
+		case name == "STATUS":
+			todo.Status = strings.ToUpper(strings.TrimSpace(value))
+		case name == "COMPLETED":
+			todo.Completed = true
+		case name == "DUE":
+			var err error
+			todo.Due, err = parseICSDue(strings.TrimSpace(value), loc)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadVTODO, err)
+	}
+	if todo == nil {
+		return nil, fmt.Errorf("%w: no VTODO", errBadVTODO)
+	}
+	return todo, nil
+}
+
+func parseICSDue(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: DUE: %w", errBadVTODO, err)
+		}
+		return dateOf(t.In(loc)), nil
+	}
+	if len(value) < len(icsDateFormat) {
+		return time.Time{}, fmt.Errorf("%w: DUE: %q", errBadVTODO, value)
+	}
+	date, err := time.Parse(icsDateFormat, value[:len(icsDateFormat)])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: DUE: %w", errBadVTODO, err)
+	}
+	return date, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalDAV(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.md")
+	const source = `- [ ] Report 📅 2024-01-15 🆔 report
+- [ ] Water plants 🔁 every week ⏳ 2024-01-15 🆔 water
+- [ ] Review #work
+- [x] Done ✅ 2024-01-14
+`
+	err := os.WriteFile(filepath.Join(dir, "skipped.md"), []byte("---\ntasks-notify:\n  skip: true\n---\n- [ ] Skipped\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC)
+	c := &CalDAV{Paths: []string{dir}, Username: "me", Password: "secret", Now: func() time.Time { return now }}
+	srv := httptest.NewServer(newServeMux(&Dashboard{Paths: []string{dir}, CalDAV: c, Now: c.Now}))
+	t.Cleanup(srv.Close)
+
+	do := func(t *testing.T, method, path string, header map[string]string, body string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("me", "secret")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var buf strings.Builder
+		_, err = io.Copy(&buf, resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, buf.String()
+	}
+	reset := func(t *testing.T) {
+		t.Helper()
+		err := os.WriteFile(file, []byte(source), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	reHref := regexp.MustCompile(`<d:response><d:href>([^<]*)</d:href>`)
+	hrefs := func(body string) (hrefs []string) {
+		for _, m := range reHref.FindAllStringSubmatch(body, -1) {
+			hrefs = append(hrefs, m[1])
+		}
+		return hrefs
+	}
+	review := caldavCollection + icsUID(file, Task{Line: "[ ] Review #work"}) + ".ics"
+
+	t.Run("auth", func(t *testing.T) {
+		reset(t)
+		req, _ := http.NewRequestWithContext(t.Context(), "PROPFIND", srv.URL+caldavPath, nil)
+		req.SetBasicAuth("me", "wrong")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("status = %d, want %d with WWW-Authenticate", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		reset(t)
+		resp, _ := do(t, http.MethodGet, "/.well-known/caldav", nil, "")
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != caldavPath {
+			t.Errorf("well-known: %d %s", resp.StatusCode, resp.Header.Get("Location"))
+		}
+		resp, body := do(t, "PROPFIND", caldavPath, map[string]string{"Depth": "0"}, `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>
+<d:current-user-principal/><c:calendar-home-set/><x:unknown xmlns:x="urn:x"/></d:prop></d:propfind>`)
+		want := `<d:response><d:href>/caldav/</d:href><d:propstat><d:prop>` +
+			`<d:current-user-principal><d:href>/caldav/</d:href></d:current-user-principal>` +
+			`<c:calendar-home-set><d:href>/caldav/</d:href></c:calendar-home-set>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat>` +
+			`<d:propstat><d:prop><x:unknown xmlns:x="urn:x"/><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`
+		if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, want) {
+			t.Errorf("root: %d\n%s", resp.StatusCode, body)
+		}
+
+		_, body = do(t, "PROPFIND", caldavPath, map[string]string{"Depth": "1"}, "")
+		if got := hrefs(body); strings.Join(got, " ") != "/caldav/ /caldav/tasks/" {
+			t.Errorf("root depth 1: %q", got)
+		}
+		if !strings.Contains(body, "<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>") ||
+			!strings.Contains(body, `<c:supported-calendar-component-set><c:comp name="VTODO"/>`) {
+			t.Errorf("no calendar:\n%s", body)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		reset(t)
+		_, body := do(t, "PROPFIND", caldavCollection, map[string]string{"Depth": "1"},
+			`<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`)
+		want := []string{caldavCollection, caldavCollection + "report.ics", caldavCollection + "water.ics", review}
+		if got := hrefs(body); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("hrefs:\n got %q\nwant %q", got, want)
+		}
+
+		_, body = do(t, "REPORT", caldavCollection, map[string]string{"Depth": "1"}, `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><d:getetag/><c:calendar-data/></d:prop>
+<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO"/></c:comp-filter></c:filter>
+</c:calendar-query>`)
+		if n := strings.Count(body, "BEGIN:VTODO&#xD;"); n != 3 {
+			t.Errorf("calendar-query: %d VTODO in\n%s", n, body)
+		}
+		_, body = do(t, "REPORT", caldavCollection, nil, `<c:calendar-query xmlns:c="urn:ietf:params:xml:ns:caldav">
+<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"/></c:comp-filter></c:filter>
+</c:calendar-query>`)
+		if got := hrefs(body); len(got) != 0 {
+			t.Errorf("calendar-query VEVENT: %q", got)
+		}
+
+		_, body = do(t, "REPORT", caldavCollection, nil, `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><c:calendar-data/></d:prop>
+<d:href>/caldav/tasks/report.ics</d:href><d:href>/caldav/tasks/missing.ics</d:href>
+</c:calendar-multiget>`)
+		if !strings.Contains(body, "SUMMARY:Report&#xD;") ||
+			!strings.Contains(body, "<d:href>/caldav/tasks/missing.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>") {
+			t.Errorf("calendar-multiget:\n%s", body)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		reset(t)
+		resp, body := do(t, http.MethodGet, caldavCollection+"water.ics", nil, "")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" ||
+			!strings.Contains(body, "UID:water@md-tasks-notify\r\n") || !strings.Contains(body, "DUE;VALUE=DATE:20240115\r\n") {
+			t.Errorf("%d %v\n%s", resp.StatusCode, resp.Header, body)
+		}
+		resp, _ = do(t, http.MethodGet, caldavCollection+"missing.ics", nil, "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("missing: status = %d", resp.StatusCode)
+		}
+	})
+
+	todo := func(props ...string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:x\r\n" + strings.Join(props, "\r\n") +
+			"\r\nBEGIN:VALARM\r\nSTATUS:CANCELLED\r\nEND:VALARM\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	}
+	tests := []struct {
+		name       string
+		path       string
+		header     map[string]string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "done recurring",
+			path:       caldavCollection + "water.ics",
+			body:       todo("STATUS:COMPLETED", "COMPLETED:20240116T080000Z", "DUE;VALUE=DATE:20240115"),
+			wantStatus: http.StatusNoContent,
+			want: strings.Replace(source, "- [ ] Water plants 🔁 every week ⏳ 2024-01-15 🆔 water\n",
+				"- [ ] Water plants 🔁 every week ⏳ 2024-01-22 🆔 water\n- [x] Water plants 🔁 every week ⏳ 2024-01-15 🆔 water ✅ 2024-01-16\n", 1),
+		},
+		{
+			name:       "in progress and due",
+			path:       review,
+			body:       todo("STATUS:IN-PROCESS", "DUE:20240119T230000Z"),
+			wantStatus: http.StatusNoContent,
+			want:       strings.Replace(source, "- [ ] Review #work\n", "- [/] Review #work 📅 2024-01-19\n", 1),
+		},
+		{
+			name:       "scheduled",
+			path:       caldavCollection + "water.ics",
+			body:       todo("STATUS:NEEDS-ACTION", "DUE;VALUE=DATE:20240120"),
+			wantStatus: http.StatusNoContent,
+			want:       strings.Replace(source, "⏳ 2024-01-15", "⏳ 2024-01-20", 1),
+		},
+		{
+			name:       "cancelled",
+			path:       caldavCollection + "report.ics",
+			body:       todo("STATUS:CANCELLED", "DUE;VALUE=DATE:20240115"),
+			wantStatus: http.StatusNoContent,
+			want:       strings.Replace(source, "- [ ] Report 📅 2024-01-15 🆔 report", "- [-] Report 📅 2024-01-15 🆔 report ❌ 2024-01-16", 1),
+		},
+		{
+			name:       "unchanged",
+			path:       caldavCollection + "report.ics",
+			body:       todo("STATUS:NEEDS-ACTION", "DUE;VALUE=DATE:20240115"),
+			wantStatus: http.StatusNoContent,
+			want:       source,
+		},
+		{
+			name:       "etag mismatch",
+			path:       caldavCollection + "report.ics",
+			header:     map[string]string{"If-Match": `"bad"`},
+			body:       todo("STATUS:COMPLETED"),
+			wantStatus: http.StatusPreconditionFailed,
+			want:       source,
+		},
+		{
+			name:       "create",
+			path:       caldavCollection + "new.ics",
+			body:       todo("SUMMARY:New"),
+			wantStatus: http.StatusForbidden,
+			want:       source,
+		},
+		{
+			name:       "bad body",
+			path:       caldavCollection + "report.ics",
+			body:       "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n",
+			wantStatus: http.StatusBadRequest,
+			want:       source,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reset(t)
+			resp, body := do(t, http.MethodPut, tc.path, tc.header, tc.body)
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tc.wantStatus, body)
+			}
+			if got := string(mustReadFile(t, file)); got != tc.want {
+				t.Errorf("file:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("put with etag", func(t *testing.T) {
+		reset(t)
+		resp, _ := do(t, http.MethodGet, review, nil, "")
+		resp, body := do(t, http.MethodPut, review, map[string]string{"If-Match": resp.Header.Get("ETag")}, todo("STATUS:COMPLETED"))
+		if resp.StatusCode != http.StatusNoContent || resp.Header.Get("ETag") != "" {
+			t.Errorf("status = %d %v: %s", resp.StatusCode, resp.Header, body)
+		}
+		if got := string(mustReadFile(t, file)); !strings.Contains(got, "- [x] Review #work ✅ 2024-01-16\n") {
+			t.Errorf("file:\n%s", got)
+		}
+	})
+}
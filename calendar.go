@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	icsDateLen     = len("20060102")
+	maxNonWorkdays = 366 // Protect from infinite loop on calendar without working days.
+)
+
+var errBadHoliday = errors.New("bad holiday")
+
+// Calendar knows which days are working days.
+// Zero value has working days from Monday to Friday and no holidays.
+type Calendar struct {
+	Holidays       map[time.Time]bool // Dates (see dateOf).
+	YearlyHolidays map[[2]int]bool    // Month and day.
+}
+
+// IsWorkday reports whether date is not a weekend day or a holiday.
+func (c *Calendar) IsWorkday(date time.Time) bool {
+	date = dateOf(date)
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday &&
+		!c.Holidays[date] &&
+		!c.YearlyHolidays[[2]int{int(date.Month()), date.Day()}]
+}
+
+// AddWorkdays returns date moved by n working days (backward if n < 0).
+// Zero n returns date itself even if it is not a working day.
+func (c *Calendar) AddWorkdays(date time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for range maxNonWorkdays {
+		if n == 0 {
+			break
+		}
+		date = date.AddDate(0, 0, step)
+		if c.IsWorkday(date) {
+			n--
+		}
+	}
+	return date
+}
+
+// BusinessWindow converts range of working days relative to today into range of days.
+func (c *Calendar) BusinessWindow(now time.Time, fromDay, toDay int) (int, int) {
+	today := dateOf(now)
+	return daysBetween(today, c.AddWorkdays(today, fromDay)), daysBetween(today, c.AddWorkdays(today, toDay))
+}
+
+// ExtendToWorkday returns toDay moved forward over following non-working days,
+// so tasks for the weekend are included in the last digest before the weekend.
+func (c *Calendar) ExtendToWorkday(now time.Time, toDay int) int {
+	date := dateOf(now).AddDate(0, 0, toDay)
+	for range maxNonWorkdays {
+		if c.IsWorkday(date.AddDate(0, 0, 1)) {
+			break
+		}
+		date = date.AddDate(0, 0, 1)
+		toDay++
+	}
+	return toDay
+}
+
+// LoadCalendar returns calendar with holidays loaded from iCalendar (.ics) or YAML file.
+//
+// YAML file must contain a list of dates in format YYYY-MM-DD or MM-DD (every year).
+// In iCalendar file all VEVENT are holidays, with support for FREQ=YEARLY recurrence.
+func LoadCalendar(path string) (*Calendar, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return nil, fmt.Errorf("load holidays: %w", err)
+	}
+	c := &Calendar{
+		Holidays:       make(map[time.Time]bool),
+		YearlyHolidays: make(map[[2]int]bool),
+	}
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		err = c.loadICS(data)
+	} else {
+		err = c.loadYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load holidays %q: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Calendar) loadYAML(data []byte) error {
+	var dates []string
+	err := yaml.Unmarshal(data, &dates)
+	if err != nil {
+		return err
+	}
+	for _, s := range dates {
+		if date, err := time.Parse(time.DateOnly, s); err == nil {
+			c.Holidays[date] = true
+		} else if date, err := time.Parse("01-02", s); err == nil {
+			c.YearlyHolidays[[2]int{int(date.Month()), date.Day()}] = true
+		} else {
+			return fmt.Errorf("%w: %q", errBadHoliday, s)
+		}
+	}
+	return nil
+}
+
+func (c *Calendar) loadICS(data []byte) error {
+	// Unfold long lines (RFC 5545 section 3.1).
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\n "), nil)
+	data = bytes.ReplaceAll(data, []byte("\n\t"), nil)
+
+	var inEvent, yearly bool
+	var start, end time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), ":")
+		name, _, _ = strings.Cut(name, ";") // Drop parameters like VALUE=DATE.
+		var err error
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, yearly, start, end = true, false, time.Time{}, time.Time{}
+			}
+		case "DTSTART":
+			start, err = parseICSDate(value)
+		case "DTEND":
+			end, err = parseICSDate(value)
+		case "RRULE":
+			yearly = strings.Contains(strings.ToUpper(value), "FREQ=YEARLY")
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return fmt.Errorf("%w: VEVENT without DTSTART", errBadHoliday)
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1) // DTEND is exclusive.
+			}
+			for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
+				if yearly {
+					c.YearlyHolidays[[2]int{int(date.Month()), date.Day()}] = true
+				} else {
+					c.Holidays[date] = true
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < icsDateLen {
+		return time.Time{}, fmt.Errorf("%w: bad date %q", errBadHoliday, value)
+	}
+	date, err := time.Parse("20060102", value[:icsDateLen])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", errBadHoliday, err)
+	}
+	return date, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCalendar(t *testing.T) {
+	const ics = "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20240101\r\n" +
+		"RRULE:FREQ=YEARLY\r\n" +
+		"SUMMARY:New Year\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20240506\r\n" +
+		"DTEND;VALUE=DATE:20240508\r\n" +
+		"SUMMARY:Two days\r\n" +
+		" off\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART:20240624T000000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	const yml = "- 2024-05-06\n- 2024-05-07 # Comment\n- 2024-06-24\n- 01-01\n"
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"holidays.ics":  ics,
+		"holidays.yaml": yml,
+		"bad.yml":       "- tomorrow\n",
+		"bad.ics":       "BEGIN:VEVENT\nDTSTART:2024\nEND:VEVENT\n",
+		"nostart.ics":   "BEGIN:VEVENT\nEND:VEVENT\n",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"holidays.ics", "holidays.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			cal, err := LoadCalendar(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for date, want := range map[string]bool{
+				"2024-01-01": false,
+				"2025-01-01": false,
+				"2024-01-02": true,
+				"2024-05-06": false,
+				"2024-05-07": false,
+				"2024-05-08": true,
+				"2024-06-24": false,
+				"2025-06-24": true,
+				"2024-06-22": false, // Saturday.
+			} {
+				d, _ := time.Parse(time.DateOnly, date)
+				if got := cal.IsWorkday(d); got != want {
+					t.Errorf("IsWorkday(%s) = %v, want %v", date, got, want)
+				}
+			}
+		})
+	}
+
+	for _, name := range []string{"bad.yml", "bad.ics", "nostart.ics", "nonexistent.yml"} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadCalendar(filepath.Join(dir, name))
+			if err == nil {
+				t.Error("LoadCalendar() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCalendarAddWorkdays(t *testing.T) {
+	friday := time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)
+	cal := &Calendar{Holidays: map[time.Time]bool{friday.AddDate(0, 0, 3): true}}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "2024-01-19"},
+		{1, "2024-01-23"},
+		{2, "2024-01-24"},
+		{-1, "2024-01-18"},
+		{-5, "2024-01-12"},
+	}
+
+	for _, tt := range tests {
+		if got := cal.AddWorkdays(friday, tt.n).Format(time.DateOnly); got != tt.want {
+			t.Errorf("AddWorkdays(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+
+	never := &Calendar{YearlyHolidays: make(map[[2]int]bool)}
+	for d := friday; d.Year() < 2026; d = d.AddDate(0, 0, 1) {
+		never.YearlyHolidays[[2]int{int(d.Month()), d.Day()}] = true
+	}
+	never.AddWorkdays(friday, 1)
+	never.ExtendToWorkday(friday, 0)
+}
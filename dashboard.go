@@ -0,0 +1,317 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	dashboardPollInterval = 2 * time.Second
+	noTag                 = "(no tag)"
+)
+
+var (
+	reTaskTag = regexp.MustCompile(`(?:^|\s)(#[\p{L}\p{N}_/-]+)`)
+
+	// Priorities in order of importance, as in Obsidian Tasks plugin.
+	priorities = []priority{
+		{"🔺", "highest"},
+		{"⏫", "high"},
+		{"🔼", "medium"},
+		{"", "normal"},
+		{"🔽", "low"},
+		{"⏬", "lowest"},
+	}
+)
+
+type priority struct{ Emoji, Name string }
+
+// taskPriority returns index in priorities of the task priority
+// or default priority of the note containing the task.
+func taskPriority(task Task, note noteSettings) int {
+	if i := priorityIndex(task.Priority); i >= 0 {
+		return i
+	}
+	if i := priorityIndex(note.Priority); i >= 0 {
+		return i
+	}
+	return priorityIndex("normal")
+}
+
+// priorityIndex returns index in priorities of priority with given name or -1.
+func priorityIndex(name string) int {
+	return slices.IndexFunc(priorities, func(p priority) bool { return p.Name == name })
+}
+
+// taskTags returns tags (with #) used in the task line and tags of the note containing the task.
+func taskTags(task Task, note noteSettings) []string {
+	var tags []string
+	for _, m := range reTaskTag.FindAllStringSubmatch(task.Line, -1) {
+		if !slices.Contains(tags, m[1]) {
+			tags = append(tags, m[1])
+		}
+	}
+	for _, tag := range note.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Dashboard is a read-only (unless Actions is set) HTML page with tasks from Paths.
+type Dashboard struct {
+	Paths         []string
+	Config        config // Files and tasks to show.
+	Window        string // Default window, see parseWindow.
+	WeekStart     time.Weekday
+	Actions       *Actions         // If not nil, show Done and Snooze buttons.
+	CalendarToken string           // Token required by calendar feed, feed is disabled if empty.
+	CalDAV        *CalDAV          // If not nil, serve CalDAV at caldavPath.
+	MaxFileSize   int64            // If > 0, larger files are skipped.
+	Now           func() time.Time // For testing.
+}
+
+type dashboardTask struct {
+	Task     Task
+	File     string
+	Line     int // 0 for upcoming occurrences of recurring tasks.
+	Text     string
+	Tags     []string
+	Priority int
+	Done     string // Action token.
+	Snooze   string // Action token.
+}
+
+type dashboardGroup struct {
+	Name  string
+	Tasks []dashboardTask
+}
+
+type dashboardPage struct {
+	Window, Group, Tag, Priority, Query string
+	Groups                              []dashboardGroup
+	GroupBy                             []string // Values for Group.
+	Priorities                          []string // Values for Priority.
+	Snooze                              string
+}
+
+// tasks returns tasks matching filters in query parameters, grouped as requested.
+//
+// Supported query parameters:
+//   - window: see parseWindow (default d.Window)
+//   - group: file (default), tag or priority
+//   - tag: only tasks with this tag
+//   - priority: only tasks with this priority
+//   - q: only tasks containing this text (case-insensitive)
+func (d *Dashboard) tasks(q map[string][]string) (*dashboardPage, error) {
+	get := func(name string) string {
+		if v := q[name]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	page := &dashboardPage{
+		Window:   cmp.Or(get("window"), d.Window),
+		Group:    cmp.Or(get("group"), "file"),
+		Tag:      get("tag"),
+		Priority: get("priority"),
+		Query:    get("q"),
+		GroupBy:  []string{"file", "tag", "priority"},
+		Snooze:   actionSnooze,
+	}
+	for _, p := range priorities {
+		page.Priorities = append(page.Priorities, p.Name)
+	}
+	if page.Tag != "" && !strings.HasPrefix(page.Tag, "#") {
+		page.Tag = "#" + page.Tag
+	}
+
+	now := d.Now()
+	fromDay, toDay, err := parseWindow(page.Window, now, d.WeekStart, nil)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := d.collect(&filterOptions{Now: now, FromDay: fromDay, ToDay: toDay})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]dashboardTask)
+	query := strings.ToLower(page.Query)
+	for _, t := range tasks {
+		switch {
+		case page.Tag != "" && !slices.Contains(t.Tags, page.Tag):
+		case page.Priority != "" && priorities[t.Priority].Name != page.Priority:
+		case query != "" && !strings.Contains(strings.ToLower(t.Text), query):
+		case page.Group == "tag" && len(t.Tags) > 0:
+			for _, tag := range t.Tags {
+				groups[tag] = append(groups[tag], t)
+			}
+		case page.Group == "tag":
+			groups[noTag] = append(groups[noTag], t)
+		case page.Group == "priority":
+			groups[priorities[t.Priority].Name] = append(groups[priorities[t.Priority].Name], t)
+		default:
+			groups[t.File] = append(groups[t.File], t)
+		}
+	}
+	names := slices.Sorted(maps.Keys(groups))
+	if page.Group == "priority" {
+		slices.SortFunc(names, func(a, b string) int { return priorityIndex(a) - priorityIndex(b) })
+	}
+	for _, name := range names {
+		tasks := groups[name]
+		slices.SortStableFunc(tasks, func(a, b dashboardTask) int {
+			return cmp.Or(cmp.Compare(a.Priority, b.Priority), cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+		})
+		page.Groups = append(page.Groups, dashboardGroup{Name: name, Tasks: tasks})
+	}
+	return page, nil
+}
+
+// collect returns tasks which main command outputs with given opts
+// (opts.Config, opts.OnMatch and opts.MaxFileSize are replaced).
+func (d *Dashboard) collect(opts *filterOptions) ([]dashboardTask, error) {
+	var tasks []dashboardTask
+	opts.Config = d.Config
+	opts.MaxFileSize = d.MaxFileSize
+	opts.OnMatch = func(filename string, note noteSettings, task Task, line int) {
+		t := dashboardTask{
+			Task:     task,
+			File:     filename,
+			Line:     line,
+			Text:     task.Line,
+			Tags:     taskTags(task, note),
+			Priority: taskPriority(task, note),
+		}
+		if d.Actions != nil && line != 0 {
+			t.Done, t.Snooze = d.Actions.Tokens(filename, task, line)
+		}
+		tasks = append(tasks, t)
+	}
+	_, _, err := filterMarkdownPaths(opts, d.Paths)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width">
+<title>Tasks</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; padding: 0 1em; }
+li { margin: .3em 0; } form.action { display: inline; } small { color: gray; }
+</style></head>
+<body>
+<form method="get">
+<input name="window" value="{{.Window}}" placeholder="window" size="12">
+<select name="group">
+{{- range .GroupBy}}
+<option{{if eq . $.Group}} selected{{end}}>{{.}}</option>
+{{- end}}
+</select>
+<input name="tag" value="{{.Tag}}" placeholder="#tag" size="10">
+<select name="priority"><option value="">any priority</option>
+{{- range .Priorities}}
+<option{{if eq . $.Priority}} selected{{end}}>{{.}}</option>
+{{- end}}
+</select>
+<input name="q" value="{{.Query}}" placeholder="search">
+<button type="submit">Filter</button>
+</form>
+{{- range .Groups}}
+<h2>{{.Name}}</h2>
+<ul>
+{{- range .Tasks}}
+<li>{{.Text}}{{if ne $.Group "file"}} <small>{{.File}}</small>{{end}}
+{{- if .Done}}
+<form class="action" method="post" action="action"><input type="hidden" name="t" value="{{.Done}}"><button>Done</button></form>
+<form class="action" method="post" action="action"><input type="hidden" name="t" value="{{.Snooze}}"><button>Snooze {{$.Snooze}}</button></form>
+{{- end}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p>No tasks.</p>
+{{- end}}
+<script>new EventSource("events").onmessage = () => location.reload();</script>
+</body></html>
+`))
+
+// ServeHTTP renders dashboard page.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := d.tasks(r.URL.Query())
+	switch {
+	case errors.Is(err, errBadWindow):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err = dashboardTemplate.Execute(w, page)
+	if err != nil {
+		log.Println("Warning: Failed to render dashboard:", err)
+	}
+}
+
+// ServeEvents sends server-sent event when Markdown files are changed.
+func (d *Dashboard) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	version := d.version()
+	ticker := time.NewTicker(dashboardPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if v := d.version(); v != version {
+				version = v
+				_, _ = fmt.Fprint(w, "data: changed\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// version returns hash of names, sizes and modification times of all Markdown files.
+func (d *Dashboard) version() string {
+	files, err := d.Config.Files.statMarkdownFiles(d.Paths)
+	if err != nil {
+		return err.Error()
+	}
+	h := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\x00", name, files[name].Size(), files[name].ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.md"), []byte(`- [ ] Low #work 🔽 📅 2024-01-15
+- [ ] High #work #home ⏫ 📅 2024-01-15
+- [ ] Tomorrow 📅 2024-01-16
+- [x] Done #work 📅 2024-01-15
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "b.md"), []byte("- [ ] Normal <b>bold</b> 📅 2024-01-15\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	d := &Dashboard{Paths: []string{dir}, Window: "today", Now: func() time.Time { return now }}
+
+	reGroup := regexp.MustCompile(`<h2>(.*)</h2>`)
+	reTask := regexp.MustCompile(`<li>(.*?)(?: <small>|<form|</li>)`)
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantGroups []string
+		wantTasks  []string
+	}{
+		{
+			query:      "",
+			wantStatus: http.StatusOK,
+			wantGroups: []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")},
+			wantTasks: []string{
+				"[ ] High #work #home ⏫ 📅 2024-01-15",
+				"[ ] Low #work 🔽 📅 2024-01-15",
+				"[ ] Normal &lt;b&gt;bold&lt;/b&gt; 📅 2024-01-15",
+			},
+		},
+		{
+			query:      "?group=tag",
+			wantStatus: http.StatusOK,
+			wantGroups: []string{"#home", "#work", noTag},
+			wantTasks: []string{
+				"[ ] High #work #home ⏫ 📅 2024-01-15",
+				"[ ] High #work #home ⏫ 📅 2024-01-15",
+				"[ ] Low #work 🔽 📅 2024-01-15",
+				"[ ] Normal &lt;b&gt;bold&lt;/b&gt; 📅 2024-01-15",
+			},
+		},
+		{
+			query:      "?group=priority&tag=work&window=next+2+days",
+			wantStatus: http.StatusOK,
+			wantGroups: []string{"high", "low"},
+			wantTasks: []string{
+				"[ ] High #work #home ⏫ 📅 2024-01-15",
+				"[ ] Low #work 🔽 📅 2024-01-15",
+			},
+		},
+		{
+			query:      "?window=tomorrow&q=TOMORROW",
+			wantStatus: http.StatusOK,
+			wantGroups: []string{filepath.Join(dir, "a.md")},
+			wantTasks:  []string{"[ ] Tomorrow 📅 2024-01-16"},
+		},
+		{
+			query:      "?priority=normal",
+			wantStatus: http.StatusOK,
+			wantGroups: []string{filepath.Join(dir, "b.md")},
+			wantTasks:  []string{"[ ] Normal &lt;b&gt;bold&lt;/b&gt; 📅 2024-01-15"},
+		},
+		{
+			query:      "?window=someday",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	mux := newServeMux(d)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			var groups, tasks []string
+			for _, m := range reGroup.FindAllStringSubmatch(body, -1) {
+				groups = append(groups, m[1])
+			}
+			for _, m := range reTask.FindAllStringSubmatch(body, -1) {
+				tasks = append(tasks, m[1])
+			}
+			if strings.Join(groups, "\n") != strings.Join(tt.wantGroups, "\n") {
+				t.Errorf("groups = %q, want %q", groups, tt.wantGroups)
+			}
+			if strings.Join(tasks, "\n") != strings.Join(tt.wantTasks, "\n") {
+				t.Errorf("tasks = %q, want %q", tasks, tt.wantTasks)
+			}
+			if strings.Contains(body, `action="action"`) {
+				t.Errorf("read-only dashboard has actions")
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/action", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("read-only POST /action status = %d", w.Code)
+	}
+
+	d.Actions = &Actions{Secret: []byte("secret"), TTL: time.Hour, Now: d.Now}
+	w = httptest.NewRecorder()
+	newServeMux(d).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if n := strings.Count(w.Body.String(), `action="action"`); n != 6 {
+		t.Errorf("action forms = %d, want 6", n)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// reDataviewField matches task field in Dataview format of Obsidian Tasks plugin,
+// e.g. [due:: 2024-01-15] or (due:: 2024-01-15).
+var reDataviewField = regexp.MustCompile(
+	`[\[(](due|scheduled|start|created|completion|cancelled|priority|repeat|onCompletion|id|dependsOn)::\s*([^\])]*?)\s*[\])]`)
+
+// dataviewDateFields maps emoji of task dates to names of Dataview fields with same dates.
+var dataviewDateFields = map[string]string{
+	"📅": "due",
+	"⏳": "scheduled",
+	"🛫": "start",
+	"✅": "completion",
+	"❌": "cancelled",
+}
+
+// dataviewFields returns task fields in Dataview format (name -> value).
+func dataviewFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range reDataviewField.FindAllStringSubmatch(line, -1) {
+		if _, ok := fields[m[1]]; !ok {
+			fields[m[1]] = m[2]
+		}
+	}
+	return fields
+}
+
+// parseDataviewFields sets properties of the task which are not set in emoji format
+// using fields in Dataview format. Fields with invalid values are ignored.
+func parseDataviewFields(task *Task) {
+	if !strings.Contains(task.Line, "::") {
+		return
+	}
+	fields := dataviewFields(task.Line)
+	for name, date := range map[string]*time.Time{"due": &task.Due, "scheduled": &task.Scheduled, "start": &task.Start} {
+		if value, ok := fields[name]; ok && date.IsZero() {
+			if t, err := time.Parse(time.DateOnly, value); err == nil {
+				*date = t
+			}
+		}
+	}
+	if value := strings.ToLower(fields["priority"]); priorityIndex(value) >= 0 && task.Priority == "" {
+		task.Priority = value
+	}
+	if value := fields["repeat"]; value != "" && task.Recurrence == "" {
+		task.Recurrence = value
+	}
+	if value := fields["id"]; value != "" && task.ID == "" {
+		task.ID = value
+	}
+	if value := fields["dependsOn"]; value != "" && len(task.DependsOn) == 0 {
+		task.DependsOn = reTaskIDSep.Split(value, -1)
+	}
+}
+
+// replaceDataviewFields returns line with values of task fields in Dataview format
+// replaced by result of repl called with name and value of each field.
+func replaceDataviewFields(line string, repl func(name, value string) string) string {
+	for _, m := range slices.Backward(reDataviewField.FindAllStringSubmatchIndex(line, -1)) {
+		line = line[:m[4]] + repl(line[m[2]:m[3]], line[m[4]:m[5]]) + line[m[5]:]
+	}
+	return line
+}
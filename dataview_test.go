@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDataviewFields(t *testing.T) {
+	tests := []struct {
+		line string
+		due  time.Time // Due date set in emoji format.
+		want Task
+	}{
+		{
+			line: "[ ] Task [due:: 2024-01-15] (scheduled:: 2024-01-14) [start::2024-01-10]",
+			want: Task{
+				Due:       time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				Scheduled: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+				Start:     time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			line: "[ ] Task [repeat:: every week] [id:: abc] [dependsOn:: x, y] [priority:: High]",
+			want: Task{Recurrence: "every week", ID: "abc", DependsOn: []string{"x", "y"}, Priority: "high"},
+		},
+		{
+			line: "[ ] Emoji wins 📅 2024-01-20 [due:: 2024-01-15] [project:: x]",
+			due:  time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC),
+			want: Task{Due: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			line: "[ ] Bad date [due:: tomorrow]",
+			want: Task{},
+		},
+		{
+			line: "[ ] Bad priority [priority:: urgent]",
+			want: Task{},
+		},
+	}
+	for _, tt := range tests {
+		task := Task{Line: tt.line, Due: tt.due}
+		parseDataviewFields(&task)
+		if !task.Due.Equal(tt.want.Due) || !task.Scheduled.Equal(tt.want.Scheduled) || !task.Start.Equal(tt.want.Start) ||
+			task.Recurrence != tt.want.Recurrence || task.ID != tt.want.ID || !slices.Equal(task.DependsOn, tt.want.DependsOn) ||
+			task.Priority != tt.want.Priority {
+			t.Errorf("parseDataviewFields(%q) = %+v, want %+v", tt.line, task, tt.want)
+		}
+	}
+}
+
+func TestDataviewFormat(t *testing.T) {
+	input := []byte(`- [ ] Dataview due [due:: 2024-01-15] [priority:: high]
+- [ ] Dataview scheduled (scheduled:: 2024-01-16)
+- [ ] Dataview later [due:: 2024-01-20]
+- [ ] Dataview not started [due:: 2024-01-15] [start:: 2024-01-16]
+- [ ] Dataview blocked [due:: 2024-01-15] [dependsOn:: a]
+- [ ] Blocker [id:: a]
+- [ ] Emoji due 📅 2024-01-15
+`)
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	tasks, _, err := filterMarkdownFiles(&filterOptions{Now: now, ToDay: 1}, map[string][]byte{"": input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- [ ] Dataview due [due:: 2024-01-15] [priority:: high]
+- [ ] Dataview scheduled (scheduled:: 2024-01-16)
+- [ ] Emoji due 📅 2024-01-15
+`
+	if got := string(tasks[""]); got != want {
+		t.Errorf("filterMarkdownFiles() =\n%s\nwant:\n%s", got, want)
+	}
+
+	parsed, _, err := parseTasks(newMarkdown(), &tasksConfig{}, []byte("- [ ] Task ⏬ [priority:: high]\n- [ ] Task [priority:: high]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"lowest", "high"} {
+		if got := priorities[taskPriority(parsed[i], noteSettings{})].Name; got != want {
+			t.Errorf("taskPriority(%q) = %q, want %s", parsed[i].Line, got, want)
+		}
+	}
+	if got := taskSummary(Task{Line: "[ ] Task [due:: 2024-01-15] [project:: x]"}); got != "Task [project:: x]" {
+		t.Errorf("taskSummary() = %q", got)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers signed by default, as recommended by RFC 6376 section 5.4.1 (when present).
+const defaultDKIMHeaders = "From:To:Cc:Subject:Date:Message-ID:Reply-To:MIME-Version:Content-Type"
+
+var (
+	errDKIMNoBody = errors.New("message has no body")
+	reWSP         = regexp.MustCompile(`[ \t]+`)
+)
+
+// DKIMConfig holds configuration for DKIM signing of outgoing emails.
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	Headers  []string      // Names of headers to sign (when present in the message).
+	Key      crypto.Signer // *rsa.PrivateKey or ed25519.PrivateKey.
+	Now      func() time.Time
+}
+
+// NewDKIMConfigFromEnv returns DKIM configuration from environment variables
+// or nil if DKIM_KEY_FILE is not set.
+// If DKIM_DOMAIN is not set, the domain of the from address is used.
+func NewDKIMConfigFromEnv(from string) (*DKIMConfig, error) {
+	keyFile := os.Getenv("DKIM_KEY_FILE")
+	if keyFile == "" {
+		return nil, nil //nolint:nilnil // DKIM is optional.
+	}
+
+	cfg := &DKIMConfig{
+		Domain:   os.Getenv("DKIM_DOMAIN"),
+		Selector: os.Getenv("DKIM_SELECTOR"),
+		Headers:  strings.Split(os.Getenv("DKIM_HEADERS"), ":"),
+		Now:      time.Now,
+	}
+	if os.Getenv("DKIM_HEADERS") == "" {
+		cfg.Headers = strings.Split(defaultDKIMHeaders, ":")
+	}
+	if cfg.Domain == "" {
+		addr, err := mail.ParseAddress(from)
+		if err != nil {
+			return nil, fmt.Errorf("DKIM_DOMAIN is not set and SMTP_FROM has no domain: %w", err)
+		}
+		cfg.Domain = addr.Address[strings.LastIndexByte(addr.Address, '@')+1:]
+	}
+	if cfg.Selector == "" {
+		return nil, errors.New("DKIM_SELECTOR is not set")
+	}
+
+	var err error
+	cfg.Key, err = loadDKIMKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadDKIMKey loads RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8) private key from PEM file.
+func loadDKIMKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return nil, fmt.Errorf("read DKIM key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("read DKIM key %q: no PEM data", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse DKIM key %q: %w", path, err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("parse DKIM key %q: unsupported key type %T", path, key)
+	}
+}
+
+// Sign returns msg with added DKIM-Signature header.
+// Signature uses relaxed/relaxed canonicalization. Line endings in msg are normalized to CRLF.
+func (c *DKIMConfig) Sign(msg []byte) ([]byte, error) {
+	msg = toCRLF(msg)
+	i := bytes.Index(msg, []byte("\r\n\r\n"))
+	if i == -1 {
+		return nil, fmt.Errorf("DKIM sign: %w", errDKIMNoBody)
+	}
+	headers := splitHeaders(msg[:i+2])
+	body := msg[i+4:]
+
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+
+	var signedNames []string
+	h := sha256.New()
+	used := make(map[int]bool)
+	for _, name := range c.Headers {
+		// Use instances of a repeated header from the bottom up (RFC 6376 section 5.4.2).
+		for j := len(headers) - 1; j >= 0; j-- {
+			if used[j] || !strings.EqualFold(headerName(headers[j]), name) {
+				continue
+			}
+			used[j] = true
+			signedNames = append(signedNames, strings.ToLower(name))
+			h.Write([]byte(dkimRelaxedHeader(headers[j])))
+			break
+		}
+	}
+
+	algo := "rsa-sha256"
+	opts := crypto.SignerOpts(crypto.SHA256)
+	if _, ok := c.Key.(ed25519.PrivateKey); ok {
+		algo = "ed25519-sha256"
+		opts = crypto.Hash(0) // RFC 8463: Ed25519 signs the SHA-256 hash.
+	}
+
+	sigHeader := "DKIM-Signature: v=1; a=" + algo + "; c=relaxed/relaxed;" +
+		" d=" + c.Domain + "; s=" + c.Selector + ";" +
+		" t=" + strconv.FormatInt(c.Now().Unix(), 10) + ";" +
+		" h=" + strings.Join(signedNames, ":") + ";" +
+		" bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ";" +
+		" b="
+	h.Write([]byte(strings.TrimSuffix(dkimRelaxedHeader(sigHeader+"\r\n"), "\r\n")))
+
+	sig, err := c.Key.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM sign: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(sigHeader)
+	buf.WriteString(base64.StdEncoding.EncodeToString(sig))
+	buf.WriteString("\r\n")
+	buf.Write(msg)
+	return buf.Bytes(), nil
+}
+
+// toCRLF converts all bare LF line endings to CRLF.
+func toCRLF(msg []byte) []byte {
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+}
+
+// splitHeaders splits CRLF-terminated header block into headers (including
+// continuation lines and trailing CRLF).
+func splitHeaders(block []byte) []string {
+	var headers []string
+	for line := range strings.SplitAfterSeq(string(block), "\r\n") {
+		switch {
+		case line == "":
+		case (line[0] == ' ' || line[0] == '\t') && len(headers) > 0:
+			headers[len(headers)-1] += line
+		default:
+			headers = append(headers, line)
+		}
+	}
+	return headers
+}
+
+func headerName(header string) string {
+	name, _, _ := strings.Cut(header, ":")
+	return strings.TrimRight(name, " \t")
+}
+
+// dkimRelaxedHeader implements "relaxed" header canonicalization (RFC 6376 section 3.4.2).
+func dkimRelaxedHeader(header string) string {
+	name, value, _ := strings.Cut(header, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = reWSP.ReplaceAllString(value, " ")
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + strings.Trim(value, " ") + "\r\n"
+}
+
+// dkimRelaxedBody implements "relaxed" body canonicalization (RFC 6376 section 3.4.4).
+func dkimRelaxedBody(body []byte) []byte {
+	var buf bytes.Buffer
+	for line := range strings.SplitSeq(string(body), "\r\n") {
+		line = reWSP.ReplaceAllString(line, " ")
+		buf.WriteString(strings.TrimRight(line, " "))
+		buf.WriteString("\r\n")
+	}
+	canon := bytes.TrimRight(buf.Bytes(), "\r\n")
+	if len(canon) == 0 {
+		return nil
+	}
+	return append(canon, '\r', '\n')
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powerman/check"
+)
+
+func TestDKIMRelaxed(tt *testing.T) {
+	t := check.T(tt)
+
+	// Example from RFC 6376 section 3.4.5.
+	headers := splitHeaders([]byte("A: X\r\nB : Y\t\r\n\tZ  \r\n"))
+	t.DeepEqual(headers, []string{"A: X\r\n", "B : Y\t\r\n\tZ  \r\n"})
+	t.Equal(dkimRelaxedHeader(headers[0]), "a:X\r\n")
+	t.Equal(dkimRelaxedHeader(headers[1]), "b:Y Z\r\n")
+	t.Equal(string(dkimRelaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))), " C\r\nD E\r\n")
+	t.Equal(string(dkimRelaxedBody([]byte("\r\n\r\n"))), "")
+}
+
+func TestLoadDKIMKey(tt *testing.T) {
+	t := check.T(tt)
+
+	dir := t.TempDir()
+	write := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		t.Nil(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+		return path
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	t.Nil(err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	t.Nil(err)
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	t.Nil(err)
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	t.Nil(err)
+
+	key, err := loadDKIMKey(write("rsa1.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)))
+	t.Nil(err)
+	t.True(rsaKey.Equal(key))
+	key, err = loadDKIMKey(write("rsa8.pem", "PRIVATE KEY", rsaPKCS8))
+	t.Nil(err)
+	t.True(rsaKey.Equal(key))
+	key, err = loadDKIMKey(write("ed.pem", "PRIVATE KEY", edPKCS8))
+	t.Nil(err)
+	t.True(edKey.Equal(key))
+
+	_, err = loadDKIMKey(write("bad.pem", "PRIVATE KEY", []byte("bad")))
+	t.Match(err, "parse DKIM key")
+	_, err = loadDKIMKey(filepath.Join(dir, "nonexistent.pem"))
+	t.Match(err, "read DKIM key")
+}
+
+func TestDKIMSign(tt *testing.T) {
+	t := check.T(tt)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	t.Nil(err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	t.Nil(err)
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		algo   string
+		verify func(hashed, sig []byte) bool
+	}{
+		{
+			name: "rsa", key: rsaKey, algo: "rsa-sha256",
+			verify: func(hashed, sig []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, hashed, sig) == nil
+			},
+		},
+		{
+			name: "ed25519", key: edKey, algo: "ed25519-sha256",
+			verify: func(hashed, sig []byte) bool { return ed25519.Verify(edPub, hashed, sig) },
+		},
+	}
+	reTag := regexp.MustCompile(`\b(\w+)=([^;]*)`)
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			t := check.T(tt)
+			cfg := &DKIMConfig{
+				Domain:   "example.com",
+				Selector: "sel",
+				Headers:  []string{"From", "To", "Subject", "Date"},
+				Key:      test.key,
+				Now:      func() time.Time { return time.Unix(1700000000, 0) },
+			}
+			msg := "To: to@example.com\r\nFrom: from@example.com\r\nSubject:  Actual\r\n  tasks\r\n\r\n- [ ] Task  1\n- [ ] Task 2\n\n"
+
+			signed, err := cfg.Sign([]byte(msg))
+			t.Nil(err)
+
+			sigHeader, rest, ok := strings.Cut(string(signed), "\r\n")
+			t.True(ok)
+			t.Equal(rest, strings.ReplaceAll(strings.ReplaceAll(msg, "\r\n", "\n"), "\n", "\r\n"))
+			tags := make(map[string]string)
+			for _, m := range reTag.FindAllStringSubmatch(sigHeader, -1) {
+				tags[m[1]] = m[2]
+			}
+			t.Equal(tags["a"], test.algo)
+			t.Equal(tags["c"], "relaxed/relaxed")
+			t.Equal(tags["d"], "example.com")
+			t.Equal(tags["s"], "sel")
+			t.Equal(tags["t"], "1700000000")
+			t.Equal(tags["h"], "from:to:subject")
+
+			bodyHash := sha256.Sum256([]byte("- [ ] Task 1\r\n- [ ] Task 2\r\n"))
+			t.Equal(tags["bh"], base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+			sig, err := base64.StdEncoding.DecodeString(tags["b"])
+			t.Nil(err)
+			unsigned := strings.TrimSuffix(sigHeader, tags["b"])
+			hashed := sha256.Sum256([]byte("from:from@example.com\r\n" +
+				"to:to@example.com\r\n" +
+				"subject:Actual tasks\r\n" +
+				strings.TrimSuffix(dkimRelaxedHeader(unsigned+"\r\n"), "\r\n")))
+			t.True(test.verify(hashed[:], sig))
+		})
+	}
+}
+
+func TestNewDKIMConfigFromEnv(tt *testing.T) {
+	t := check.T(tt)
+
+	cfg, err := NewDKIMConfigFromEnv("First Last <from@example.com>")
+	t.Nil(err)
+	t.Nil(cfg)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	t.Nil(err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	t.Nil(err)
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+	t.Nil(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	tt.Setenv("DKIM_KEY_FILE", keyFile)
+	_, err = NewDKIMConfigFromEnv("First Last <from@example.com>")
+	t.Match(err, "DKIM_SELECTOR")
+
+	tt.Setenv("DKIM_SELECTOR", "sel")
+	_, err = NewDKIMConfigFromEnv("md-tasks-notify")
+	t.Match(err, "DKIM_DOMAIN")
+
+	cfg, err = NewDKIMConfigFromEnv("First Last <from@mail.example.com>")
+	t.Nil(err)
+	t.Equal(cfg.Domain, "mail.example.com")
+	t.Equal(cfg.Selector, "sel")
+	t.Equal(strings.Join(cfg.Headers, ":"), defaultDKIMHeaders)
+
+	tt.Setenv("DKIM_DOMAIN", "example.com")
+	tt.Setenv("DKIM_HEADERS", "From:Subject")
+	cfg, err = NewDKIMConfigFromEnv("md-tasks-notify")
+	t.Nil(err)
+	t.Equal(cfg.Domain, "example.com")
+	t.DeepEqual(cfg.Headers, []string{"From", "Subject"})
+}
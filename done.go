@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errTaskNotFound  = errors.New("task not found")
+	errTaskAmbiguous = errors.New("task reference is ambiguous")
+	errTaskNotOpen   = errors.New("task is not open")
+
+	reTaskFileLine = regexp.MustCompile(`^(.+):(\d+)$`)
+	reTaskBlockID  = regexp.MustCompile(`\s\^([\w-]+)\s*$`)
+	reTaskNextDate = regexp.MustCompile(`(📅|⏳|🛫)(\x{FE0F}?\s*)(\d{4}-\d{2}-\d{2})`)
+)
+
+// doneMain implements "done" command and returns exit code.
+func doneMain(args []string) int {
+	fs := flag.NewFlagSet("done", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s done [flags] TASK [PATH ...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(fs.Output(), "Mark task as done. TASK is 🆔 of the task, its block ID (^id) or FILE:LINE.")
+		_, _ = fmt.Fprintln(fs.Output(), "Tasks with 🆔 or block ID are searched in PATHs (default current directory).")
+		_, _ = fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	date := fs.String("date", "", "Use this date (YYYY-MM-DD) as today")
+	tz := fs.String("tz", "", "Use this timezone (e.g. Europe/Kyiv) to detect today (default local)")
+	var cfg config
+	fileFlags(fs, &cfg.Files)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	now, err := parseNow(*date, *tz)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	cfg.Tasks.load(fs.Args()[1:])
+
+	err = runDone(&cfg, now, os.Stdout, fs.Arg(0), fs.Args()[1:])
+	if err != nil {
+		log.Fatalln("Failed to", err)
+	}
+	return 0
+}
+
+// runDone marks task referenced by ref as done and reports changed file to stdout.
+func runDone(cfg *config, now time.Time, stdout io.Writer, ref string, paths []string) error {
+	filename, line, err := findTask(cfg, ref, paths)
+	if err != nil {
+		return fmt.Errorf("find task %q: %w", ref, err)
+	}
+	task, err := completeTaskInFile(&cfg.Tasks, filename, line, dateOf(now))
+	if err != nil {
+		return fmt.Errorf("complete task %q: %w", ref, err)
+	}
+	_, err = fmt.Fprintf(stdout, "%s:%d: %s\n", filename, line, task)
+	return err
+}
+
+// findTask returns location of the task referenced by ref.
+// Ref is FILE:LINE, ^BLOCK-ID or 🆔 of the task, last two are searched in paths.
+func findTask(cfg *config, ref string, paths []string) (filename string, line int, err error) {
+	if m := reTaskFileLine.FindStringSubmatch(ref); m != nil {
+		if _, err := os.Stat(m[1]); err == nil {
+			line, err = strconv.Atoi(m[2])
+			return m[1], line, err
+		}
+	}
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := cfg.Files.readMarkdownFiles(paths)
+	if err != nil {
+		return "", 0, err
+	}
+	blockID, isBlockID := strings.CutPrefix(ref, "^")
+	md := newMarkdown()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		data := files[name]
+		items, err := parseListItems(md, &cfg.Tasks, data)
+		if err != nil {
+			return "", 0, fmt.Errorf("parse %q: %w", name, err)
+		}
+		for _, task := range items {
+			m := reTaskBlockID.FindStringSubmatch(task.Line)
+			if (isBlockID && m != nil && m[1] == blockID) || (!isBlockID && task.ID == ref) {
+				if filename != "" {
+					return "", 0, fmt.Errorf("%w: found at %s:%d and %s:%d",
+						errTaskAmbiguous, filename, line, name, lineOf(data, task.Offset))
+				}
+				filename, line = name, lineOf(data, task.Offset)
+			}
+		}
+	}
+	if filename == "" {
+		return "", 0, errTaskNotFound
+	}
+	return filename, line, nil
+}
+
+// completeTaskInFile marks as done the task at given line of the file and returns changed line.
+func completeTaskInFile(cfg *tasksConfig, filename string, line int, today time.Time) (string, error) {
+	data, err := os.ReadFile(filename) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return "", err
+	}
+	result, changed, err := completeTask(cfg, data, line, today)
+	if err != nil {
+		return "", err
+	}
+
+	err = replaceFile(filename, data, result, false)
+	if err != nil {
+		return "", err
+	}
+	return changed, nil
+}
+
+// openTaskAt returns not done task at given line of Markdown source.
+func openTaskAt(cfg *tasksConfig, source []byte, line int) (Task, error) {
+	items, err := parseListItems(newMarkdown(), cfg, source)
+	if err != nil {
+		return Task{}, err
+	}
+	i := slices.IndexFunc(items, func(t Task) bool { return lineOf(source, t.Offset) == line })
+	if i == -1 || !items[i].IsTask {
+		return Task{}, fmt.Errorf("%w at line %d", errTaskNotFound, line)
+	}
+	if !isOpen(items[i]) {
+		return Task{}, fmt.Errorf("%w: %s", errTaskNotOpen, items[i].Line)
+	}
+	return items[i], nil
+}
+
+// completeTask marks as done the task at given line of Markdown source.
+// It returns modified source and changed line of the task.
+// All other bytes of source are kept as is.
+//
+// Like Obsidian Tasks plugin it appends ✅ date (or [completion:: date] for tasks
+// in Dataview format) before block ID, if any, and for recurring tasks inserts
+// next occurrence above the completed task.
+func completeTask(cfg *tasksConfig, source []byte, line int, today time.Time) ([]byte, string, error) {
+	task, err := openTaskAt(cfg, source, line)
+	if err != nil {
+		return nil, "", err
+	}
+	m := reTaskCheckbox.FindStringSubmatchIndex(task.Line)
+	if m == nil {
+		return nil, "", fmt.Errorf("%w at line %d", errTaskNotFound, line)
+	}
+
+	text := strings.TrimRight(task.Line, " \t\r\n")
+	done := setTaskDate(text[:m[2]]+"x"+text[m[3]:], "✅", today)
+
+	lineStart := bytes.LastIndexByte(source[:task.Offset], '\n') + 1
+	var buf bytes.Buffer
+	buf.Write(source[:lineStart])
+	if next, ok := nextOccurrence(task, today); ok {
+		buf.Write(source[lineStart:task.Offset])
+		buf.WriteString(next)
+		buf.WriteByte('\n')
+	}
+	buf.Write(source[lineStart:task.Offset])
+	buf.WriteString(done)
+	buf.Write(source[task.Offset+len(text):])
+	return buf.Bytes(), done, nil
+}
+
+// nextOccurrence returns line of the next occurrence of recurring task
+// with dates (in emoji and Dataview formats) moved to the next occurrence,
+// open status and without block ID.
+func nextOccurrence(task Task, today time.Time) (string, bool) {
+	if task.Recurrence == "" {
+		return "", false
+	}
+	rec, err := parseRecurrence(task.Recurrence)
+	if err != nil {
+		log.Printf("Warning: Next occurrence of %q is not created: %s.", task.Line, err)
+		return "", false
+	}
+	ref := task.ReferenceDate()
+	if ref.IsZero() {
+		return "", false
+	}
+	from := ref
+	if rec.WhenDone {
+		from = today
+	}
+	days := daysBetween(ref, rec.Next(from))
+
+	line := strings.TrimRight(task.Line, " \t\r\n")
+	if loc := reTaskBlockID.FindStringIndex(line); loc != nil {
+		line = line[:loc[0]]
+	}
+	line = reTaskNextDate.ReplaceAllStringFunc(line, func(s string) string {
+		m := reTaskNextDate.FindStringSubmatch(s)
+		date, err := time.Parse(time.DateOnly, m[3])
+		if err != nil {
+			return s
+		}
+		return m[1] + m[2] + date.AddDate(0, 0, days).Format(time.DateOnly)
+	})
+	line = replaceDataviewFields(line, func(name, value string) string {
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil || (name != "due" && name != "scheduled" && name != "start") {
+			return value
+		}
+		return date.AddDate(0, 0, days).Format(time.DateOnly)
+	})
+	return reTaskCheckbox.ReplaceAllString(line, "[ ] "), true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompleteTask(t *testing.T) {
+	today := time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		source  string
+		line    int
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "Simple",
+			source: "# Tasks\n\n- [ ] Task 📅 2024-01-15\n- [ ] Other\n",
+			line:   3,
+			want:   "# Tasks\n\n- [x] Task 📅 2024-01-15 ✅ 2024-01-17\n- [ ] Other\n",
+		},
+		{
+			name:   "In progress, nested, CRLF",
+			source: "- [ ] Parent\r\n    * [/] Child  \r\n      More text\r\n",
+			line:   2,
+			want:   "- [ ] Parent\r\n    * [x] Child ✅ 2024-01-17  \r\n      More text\r\n",
+		},
+		{
+			name:   "Block ID",
+			source: "- [ ] Task ^abc\n",
+			line:   1,
+			want:   "- [x] Task ✅ 2024-01-17 ^abc\n",
+		},
+		{
+			name:   "Recurring",
+			source: "- [ ] Weekly 🔁 every week 🛫 2024-01-13 📅 2024-01-15 ^abc\n",
+			line:   1,
+			want: "- [ ] Weekly 🔁 every week 🛫 2024-01-20 📅 2024-01-22\n" +
+				"- [x] Weekly 🔁 every week 🛫 2024-01-13 📅 2024-01-15 ✅ 2024-01-17 ^abc\n",
+		},
+		{
+			name:   "Recurring when done",
+			source: "1. [ ] Water 🔁 every 3 days when done ⏳ 2024-01-10\n",
+			line:   1,
+			want: "1. [ ] Water 🔁 every 3 days when done ⏳ 2024-01-20\n" +
+				"1. [x] Water 🔁 every 3 days when done ⏳ 2024-01-10 ✅ 2024-01-17\n",
+		},
+		{
+			name:   "Dataview",
+			source: "- [ ] Task [due:: 2024-01-15] ^abc\n",
+			line:   1,
+			want:   "- [x] Task [due:: 2024-01-15] [completion:: 2024-01-17] ^abc\n",
+		},
+		{
+			name:   "Dataview recurring",
+			source: "- [ ] Weekly [due:: 2024-01-15] (start:: 2024-01-13) [repeat:: every week]\n",
+			line:   1,
+			want: "- [ ] Weekly [due:: 2024-01-22] (start:: 2024-01-20) [repeat:: every week]\n" +
+				"- [x] Weekly [due:: 2024-01-15] (start:: 2024-01-13) [repeat:: every week] [completion:: 2024-01-17]\n",
+		},
+		{
+			name:    "Already done",
+			source:  "- [x] Task ✅ 2024-01-15\n",
+			line:    1,
+			wantErr: errTaskNotOpen,
+		},
+		{
+			name:    "Not a task",
+			source:  "Text\n- Item\n",
+			line:    2,
+			wantErr: errTaskNotFound,
+		},
+		{
+			name:    "No such line",
+			source:  "- [ ] Task\n",
+			line:    2,
+			wantErr: errTaskNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := completeTask(&tasksConfig{}, []byte(tt.source), tt.line, today)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("completeTask() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("completeTask() =\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDone(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	write := func() {
+		t.Helper()
+		err := os.WriteFile(a, []byte("- [ ] Draft 🆔 draft\n- [ ] Publish ^pub\n"), 0o600)
+		if err == nil {
+			err = os.WriteFile(b, []byte("Notes\n\n- [ ] Review 🆔 review\n"), 0o600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 1, 17, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		ref     string
+		file    string
+		want    string
+		wantErr error
+	}{
+		{ref: "draft", file: a, want: "- [x] Draft 🆔 draft ✅ 2024-01-17\n- [ ] Publish ^pub\n"},
+		{ref: "^pub", file: a, want: "- [ ] Draft 🆔 draft\n- [x] Publish ✅ 2024-01-17 ^pub\n"},
+		{ref: b + ":3", file: b, want: "Notes\n\n- [x] Review 🆔 review ✅ 2024-01-17\n"},
+		{ref: "pub", wantErr: errTaskNotFound},
+		{ref: b + ":1", wantErr: errTaskNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			write()
+			var stdout bytes.Buffer
+			err := runDone(&config{}, now, &stdout, tt.ref, []string{dir})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runDone() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	errConcurrentModification = errors.New("file was modified concurrently")
+	errEditConflict           = errors.New("edit does not match source")
+)
+
+// taskEdit is a change of the first line of a task.
+type taskEdit struct {
+	Offset int    // Offset of Old in Markdown source.
+	Old    string // Text to replace, usually Task.Line without trailing spaces.
+	New    string
+}
+
+// editOptions defines how editFile applies changes.
+type editOptions struct {
+	DryRun bool // Output diff instead of changing files.
+	Backup bool // Keep original file with .bak suffix.
+}
+
+// editFile changes the file using edits returned by edit for the file content.
+// Changed lines (or diff if opts.DryRun) are reported to stdout.
+// File is replaced atomically, and only if it wasn't modified while processing.
+func editFile(filename string, opts editOptions, stdout io.Writer, edit func(source []byte) ([]taskEdit, error)) error {
+	data, err := os.ReadFile(filename) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return err
+	}
+	edits, err := edit(data)
+	if err != nil || len(edits) == 0 {
+		return err
+	}
+	result, err := applyEdits(data, edits)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		return writeDiff(stdout, filename, data, edits)
+	}
+	err = replaceFile(filename, data, result, opts.Backup)
+	if err != nil {
+		return err
+	}
+	for _, e := range edits {
+		_, err = fmt.Fprintf(stdout, "%s:%d: %s\n", filename, lineOf(data, e.Offset), e.New)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits returns copy of source with non-overlapping edits applied.
+func applyEdits(source []byte, edits []taskEdit) ([]byte, error) {
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b taskEdit) int { return a.Offset - b.Offset })
+	var buf bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		end := e.Offset + len(e.Old)
+		if e.Offset < pos || end > len(source) || string(source[e.Offset:end]) != e.Old {
+			return nil, fmt.Errorf("%w at line %d", errEditConflict, lineOf(source, e.Offset))
+		}
+		buf.Write(source[pos:e.Offset])
+		buf.WriteString(e.New)
+		pos = end
+	}
+	buf.Write(source[pos:])
+	return buf.Bytes(), nil
+}
+
+// writeDiff outputs edits in unified diff format.
+func writeDiff(w io.Writer, filename string, source []byte, edits []taskEdit) error {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "--- %s\n+++ %s\n", filename, filename)
+	for _, e := range edits {
+		start := bytes.LastIndexByte(source[:e.Offset], '\n') + 1
+		end := e.Offset + len(e.Old)
+		if i := bytes.IndexByte(source[end:], '\n'); i >= 0 {
+			end += i
+		} else {
+			end = len(source)
+		}
+		line := lineOf(source, e.Offset)
+		prefix, suffix := source[start:e.Offset], strings.TrimRight(string(source[e.Offset+len(e.Old):end]), "\r")
+		_, _ = fmt.Fprintf(&buf, "@@ -%d +%d @@\n-%s%s%s\n+%s%s%s\n", line, line,
+			prefix, e.Old, suffix, prefix, e.New, suffix)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// replaceFile atomically replaces content of the file with data if it still has old content.
+// If backup is true then old content is saved into file with .bak suffix.
+func replaceFile(filename string, old, data []byte, backup bool) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), ".md-tasks-notify-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Will fail after successful rename.
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(info.Mode())
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(filename) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, old) {
+		return fmt.Errorf("%w: %s", errConcurrentModification, filename)
+	}
+	if backup {
+		err = os.WriteFile(filename+".bak", old, info.Mode())
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// setTaskDate returns task line with date after emoji (📅, ⏳ or 🛫) replaced by date.
+// If there is no such date in line then same field in Dataview format (e.g. [due:: …])
+// is replaced instead. If there is no such field too then date is appended (before
+// block ID, if any), in Dataview format if line already has Dataview fields.
+// Emoji ✅ and ❌ are also supported, but their emoji dates are appended, not replaced.
+func setTaskDate(line, emoji string, date time.Time) string {
+	value := date.Format(time.DateOnly)
+	found := false
+	line = reTaskNextDate.ReplaceAllStringFunc(line, func(s string) string {
+		m := reTaskNextDate.FindStringSubmatch(s)
+		if m[1] != emoji {
+			return s
+		}
+		found = true
+		return m[1] + m[2] + value
+	})
+	name := dataviewDateFields[emoji]
+	if !found {
+		line = replaceDataviewFields(line, func(field, old string) string {
+			if field != name {
+				return old
+			}
+			found = true
+			return value
+		})
+	}
+	if found {
+		return line
+	}
+	insertAt := len(line)
+	if loc := reTaskBlockID.FindStringIndex(line); loc != nil {
+		insertAt = loc[0]
+	}
+	field := " " + emoji + " " + value
+	if reDataviewField.MatchString(line) {
+		field = " [" + name + ":: " + value + "]"
+	}
+	return line[:insertAt] + field + line[insertAt:]
+}
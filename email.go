@@ -6,7 +6,10 @@ import (
 	"log"
 	"net/smtp"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -22,6 +25,8 @@ type EmailConfig struct {
 	Username string // May be empty when auth not needed.
 	Password string
 	From     string
+	DKIM     *DKIMConfig                                             // May be nil to not sign emails.
+	Outbox   *Outbox                                                 // May be nil to not retry failed emails.
 	SendMail func(string, smtp.Auth, string, []string, []byte) error // For testing
 }
 
@@ -90,13 +95,53 @@ func (e *Email) Send(to, subject string, content io.Reader) error {
 	}
 
 	// Compose email message
-	msg := fmt.Sprintf("To: %s\r\n"+
+	msg := []byte(fmt.Sprintf("To: %s\r\n"+
 		"From: %s\r\n"+
 		"Subject: %s\r\n"+
 		"Content-Type: text/plain; charset=UTF-8\r\n"+
 		"\r\n"+
-		"%s", to, e.cfg.From, subject, body)
+		"%s", to, e.cfg.From, subject, body))
 
+	if e.cfg.DKIM != nil {
+		msg, err = e.cfg.DKIM.Sign(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = e.sendMail(e.cfg.From, []string{to}, msg)
+	if err != nil && e.cfg.Outbox != nil && !isPermanentSMTPError(err) {
+		if errPut := e.cfg.Outbox.Put(e.cfg.From, []string{to}, msg, err); errPut != nil {
+			log.Println("Warning: Failed to queue email for retry:", errPut)
+		} else {
+			return fmt.Errorf("send email (queued for retry): %w", err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+
+	return nil
+}
+
+// Retry sends emails queued in the outbox after previous failures (if outbox is configured).
+func (e *Email) Retry() error {
+	if e.cfg.Outbox == nil {
+		return nil
+	}
+	return e.cfg.Outbox.Flush(e.sendMail)
+}
+
+// NextRetry returns time of the next attempt to send emails queued in the outbox
+// or zero time if there are none (or outbox is not configured).
+func (e *Email) NextRetry() (time.Time, error) {
+	if e.cfg.Outbox == nil {
+		return time.Time{}, nil
+	}
+	return e.cfg.Outbox.NextAttempt()
+}
+
+func (e *Email) sendMail(from string, to []string, msg []byte) error {
 	// Connect to SMTP server
 	var auth smtp.Auth
 	if e.cfg.Username != "" {
@@ -104,10 +149,39 @@ func (e *Email) Send(to, subject string, content io.Reader) error {
 	}
 	addr := fmt.Sprintf("%s:%d", e.cfg.Host, e.cfg.Port)
 
-	err = e.cfg.SendMail(addr, auth, e.cfg.From, []string{to}, []byte(msg))
-	if err != nil {
-		return fmt.Errorf("send email: %w", err)
+	return e.cfg.SendMail(addr, auth, from, to, msg)
+}
+
+// dryRunSendMail returns EmailConfig.SendMail replacement which writes the message
+// to w instead of sending it.
+func dryRunSendMail(w io.Writer) func(string, smtp.Auth, string, []string, []byte) error {
+	return func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		_, err := fmt.Fprintf(w, "SMTP %s MAIL FROM:<%s> RCPT TO:<%s>\n%s\n",
+			addr, from, strings.Join(to, ">,<"), msg)
+		return err
 	}
+}
 
-	return nil
+// emlSendMail returns EmailConfig.SendMail replacement which writes the message
+// into a new .eml file in dir instead of sending it.
+func emlSendMail(dir string) func(string, smtp.Auth, string, []string, []byte) error {
+	return func(_ string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+		err := os.MkdirAll(dir, 0o700)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, "md-tasks-notify-*.eml")
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(msg)
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Email written to %q.", filepath.Clean(f.Name()))
+		return nil
+	}
 }
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/smtp"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/powerman/check"
@@ -227,3 +229,48 @@ func TestSendEmail(tt *testing.T) {
 		})
 	}
 }
+
+func TestDryRunSendMail(tt *testing.T) {
+	t := check.T(tt)
+
+	var out bytes.Buffer
+	email := NewEmail(&EmailConfig{
+		Host:     "localhost",
+		Port:     25,
+		From:     "from@example.com",
+		SendMail: dryRunSendMail(&out),
+	})
+
+	err := email.Send("to@example.com", "Test Subject", strings.NewReader("Hello, World!"))
+	t.Nil(err)
+	t.Contains(out.String(), "SMTP localhost:25 MAIL FROM:<from@example.com> RCPT TO:<to@example.com>\n")
+	t.Contains(out.String(), "Subject: Test Subject\r\n")
+	t.Contains(out.String(), "\r\n\r\nHello, World!\n")
+}
+
+func TestEMLSendMail(tt *testing.T) {
+	t := check.T(tt)
+
+	dir := filepath.Join(t.TempDir(), "eml")
+	email := NewEmail(&EmailConfig{
+		Host:     "localhost",
+		Port:     25,
+		From:     "from@example.com",
+		SendMail: emlSendMail(dir),
+	})
+
+	for range 2 {
+		err := email.Send("to@example.com", "Test Subject", strings.NewReader("Hello, World!"))
+		t.Nil(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	t.Nil(err)
+	t.Len(files, 2)
+	for _, file := range files {
+		msg, err := os.ReadFile(file)
+		t.Nil(err)
+		t.HasPrefix(string(msg), "To: to@example.com\r\n")
+		t.HasSuffix(string(msg), "\r\n\r\nHello, World!")
+	}
+}
@@ -1,27 +1,126 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 )
 
-func isMarkdownFile(path string) bool {
-	return strings.EqualFold(filepath.Ext(path), ".md")
+const defaultMaxFileSize = 16 << 20
+
+var errFileTooLarge = errors.New("file is larger")
+
+// defaultMarkdownExts are extensions of Markdown files used if -ext is not given.
+var defaultMarkdownExts = []string{".md"}
+
+// fileFilter selects files to search for tasks in PATHs, see fileFlags.
+// Zero value searches all not ignored .md files.
+type fileFilter struct {
+	Exts    []string         // Extensions of Markdown files (default defaultMarkdownExts).
+	Include []*regexp.Regexp // If not empty, only matching files are searched for tasks.
+	Exclude []string         // Gitignore-like patterns relative to each directory given as PATH.
+}
+
+// fileFlags defines flags which set f.
+func fileFlags(fs *flag.FlagSet, f *fileFilter) {
+	fs.Func("ext", "Comma-separated extensions of Markdown files (default .md)", func(value string) error {
+		f.Exts = nil
+		for ext := range strings.SplitSeq(value, ",") {
+			ext = strings.TrimSpace(ext)
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			f.Exts = append(f.Exts, ext)
+		}
+		if len(f.Exts) == 0 {
+			return errors.New("no extensions")
+		}
+		return nil
+	})
+	fs.Func("include", "Search only files matching this gitignore-like pattern, e.g. **/*-tasks.md (can be repeated)",
+		func(pattern string) error {
+			re, err := compileGlob(".", pattern)
+			if err == nil {
+				f.Include = append(f.Include, re)
+			}
+			return err
+		})
+	fs.Func("exclude", "Skip files and directories matching this gitignore-like pattern (can be repeated)",
+		func(pattern string) error {
+			f.Exclude = append(f.Exclude, pattern)
+			return nil
+		})
+}
+
+func (f *fileFilter) isMarkdownFile(path string) bool {
+	exts := f.Exts
+	if len(exts) == 0 {
+		exts = defaultMarkdownExts
+	}
+	return slices.ContainsFunc(exts, func(ext string) bool { return strings.EqualFold(filepath.Ext(path), ext) })
+}
+
+// isIncluded reports whether file must be searched for tasks: it is a Markdown file
+// and matches -include patterns (if any). Path must be relative to searched
+// directory or as given by user.
+func (f *fileFilter) isIncluded(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	return f.isMarkdownFile(path) &&
+		(len(f.Include) == 0 || slices.ContainsFunc(f.Include, func(re *regexp.Regexp) bool { return re.MatchString(path) }))
+}
+
+// isIncludedFile is like isIncluded for a file given by user (absolute path is
+// preferred): it must be searched if it is found while searching any of its
+// parent directories, so -include patterns match it in the same way.
+func (f *fileFilter) isIncludedFile(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for {
+		if f.isIncluded(path) {
+			return true
+		}
+		_, rest, ok := strings.Cut(path, "/")
+		if !ok || rest == "" {
+			return false
+		}
+		path = rest
+	}
+}
+
+// warnSkipped logs why file given by user is not searched for tasks.
+func (f *fileFilter) warnSkipped(path string) {
+	if f.isMarkdownFile(path) {
+		log.Printf("Warning: Skipping %q as it does not match -include.", path)
+	} else {
+		log.Printf("Warning: Skipping %q as it is not a Markdown file.", path)
+	}
+}
+
+// findMarkdownFiles collects all .md files from a directory recursively,
+// except ignored ones (see ignoreRules).
+func (f *fileFilter) findMarkdownFiles(fsys fs.FS, dir string) ([]string, error) {
+	return f.findFiles(newIgnoreRules(fsys, dir, ".", f.Exclude))
 }
 
-// findMarkdownFiles collects all .md files from a directory recursively.
-func findMarkdownFiles(fsys fs.FS, dir string) ([]string, error) {
+// findFiles returns Markdown files in ir.dir and its not ignored subdirectories,
+// with paths relative to ir.fsys.
+func (f *fileFilter) findFiles(ir *ignoreRules) ([]string, error) {
 	var files []string
-	err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+	err := ir.walk(func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !d.IsDir() && isMarkdownFile(d.Name()) {
+		if !d.IsDir() && f.isIncluded(ir.relDir(path)) {
 			files = append(files, path)
 		}
 		return nil
@@ -29,8 +128,31 @@ func findMarkdownFiles(fsys fs.FS, dir string) ([]string, error) {
 	return files, err
 }
 
-// readMarkdownFilesFromFS reads markdown files from a filesystem.
-func readMarkdownFilesFromFS(fsys fs.FS, paths []string) (map[string][]byte, error) {
+// dirIgnoreRules returns ignore rules for directory dir (absolute path) given
+// by user and absolute path of their root: Obsidian vault containing dir (so
+// vault's settings and ignore files are used for its subdirectories too) or dir.
+func (f *fileFilter) dirIgnoreRules(dir string) (_ *ignoreRules, root string) {
+	root = findVault(dir)
+	rel, err := filepath.Rel(root, dir)
+	if root == "" || err != nil {
+		root, rel = dir, "."
+	}
+	return newIgnoreRules(os.DirFS(root), ".", filepath.ToSlash(rel), f.Exclude), root
+}
+
+// findDirFiles returns absolute paths of Markdown files in directory dir
+// (absolute path) given by user, see dirIgnoreRules.
+func (f *fileFilter) findDirFiles(dir string) ([]string, error) {
+	ir, root := f.dirIgnoreRules(dir)
+	files, err := f.findFiles(ir)
+	for i := range files {
+		files[i] = filepath.Join(root, filepath.FromSlash(files[i]))
+	}
+	return files, err
+}
+
+// listMarkdownFilesFromFS returns markdown files from paths in a filesystem.
+func (f *fileFilter) listMarkdownFilesFromFS(fsys fs.FS, paths []string) ([]string, error) {
 	var mdFiles []string
 	for _, path := range paths {
 		info, err := fs.Stat(fsys, path)
@@ -40,7 +162,7 @@ func readMarkdownFilesFromFS(fsys fs.FS, paths []string) (map[string][]byte, err
 
 		switch {
 		case info.IsDir():
-			files, err := findMarkdownFiles(fsys, path)
+			files, err := f.findMarkdownFiles(fsys, path)
 			if err != nil {
 				return nil, err
 			}
@@ -48,15 +170,21 @@ func readMarkdownFilesFromFS(fsys fs.FS, paths []string) (map[string][]byte, err
 				log.Printf("Warning: Directory %q contains no Markdown files.", path)
 			}
 			mdFiles = append(mdFiles, files...)
-		case isMarkdownFile(path):
+		case f.isIncludedFile(path):
 			mdFiles = append(mdFiles, path)
 		default:
-			log.Printf("Warning: Skipping %q as it is not a Markdown file.", path)
+			f.warnSkipped(path)
 		}
 	}
 
-	if len(mdFiles) == 0 {
-		return make(map[string][]byte), nil
+	return mdFiles, nil
+}
+
+// readMarkdownFilesFromFS reads markdown files from a filesystem.
+func (f *fileFilter) readMarkdownFilesFromFS(fsys fs.FS, paths []string) (map[string][]byte, error) {
+	mdFiles, err := f.listMarkdownFilesFromFS(fsys, paths)
+	if err != nil {
+		return nil, err
 	}
 
 	result := make(map[string][]byte)
@@ -71,9 +199,9 @@ func readMarkdownFilesFromFS(fsys fs.FS, paths []string) (map[string][]byte, err
 	return result, nil
 }
 
-// readMarkdownFiles reads markdown files from paths.
-func readMarkdownFiles(paths []string) (map[string][]byte, error) {
-	result := make(map[string][]byte)
+// listMarkdownFiles returns sorted absolute paths of markdown files from paths.
+func (f *fileFilter) listMarkdownFiles(paths []string) ([]string, error) {
+	var result []string
 	for _, path := range paths {
 		absPath, err := filepath.Abs(path)
 		if err != nil {
@@ -83,33 +211,67 @@ func readMarkdownFiles(paths []string) (map[string][]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-
-		// Normalize: for directories, append "." to make them look like files.
-		targetPath := absPath
-		if info.IsDir() {
-			targetPath = filepath.Join(absPath, ".")
+		if !info.IsDir() { // Checked here because -include may need a path, not only a base name.
+			if f.isIncludedFile(absPath) {
+				result = append(result, absPath)
+			} else {
+				f.warnSkipped(path)
+			}
+			continue
 		}
 
-		// Now we can handle both files and directories uniformly.
-		dir := filepath.Dir(targetPath)
-		baseName := filepath.Base(targetPath)
-		filesData, err := readMarkdownFilesFromFS(os.DirFS(dir), []string{baseName})
+		files, err := f.findDirFiles(absPath)
 		if err != nil {
 			return nil, err
 		}
+		if len(files) == 0 {
+			log.Printf("Warning: Directory %q contains no Markdown files.", path)
+		}
+		result = append(result, files...)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
 
-		// Convert relative paths to absolute.
-		for filename, data := range filesData {
-			result[filepath.Join(dir, filename)] = data
+// readMarkdownFiles reads markdown files from paths.
+func (f *fileFilter) readMarkdownFiles(paths []string) (map[string][]byte, error) {
+	filenames, err := f.listMarkdownFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte)
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename) //nolint:gosec // Path is provided by user.
+		if err != nil {
+			return nil, err
 		}
+		result[filename] = data
 	}
 	return result, nil
 }
 
+// readMarkdownFile reads the file, or returns error wrapping errFileTooLarge
+// if it is larger than maxSize bytes (if maxSize > 0).
+func readMarkdownFile(filename string, maxSize int64) ([]byte, error) {
+	f, err := os.Open(filename) //nolint:gosec // Path is provided by user.
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // Read-only.
+	if maxSize <= 0 {
+		return io.ReadAll(f)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err == nil && int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%q: %w than %d bytes", filename, errFileTooLarge, maxSize)
+	}
+	return data, err
+}
+
 // readMarkdownFilesOrStdin reads markdown files from paths or stdin.
-func readMarkdownFilesOrStdin(paths []string) (map[string][]byte, error) {
+func (f *fileFilter) readMarkdownFilesOrStdin(paths []string) (map[string][]byte, error) {
 	if len(paths) > 0 {
-		return readMarkdownFiles(paths)
+		return f.readMarkdownFiles(paths)
 	}
 
 	data, err := io.ReadAll(os.Stdin)
@@ -118,3 +280,35 @@ func readMarkdownFilesOrStdin(paths []string) (map[string][]byte, error) {
 	}
 	return map[string][]byte{"": data}, nil
 }
+
+// statMarkdownFiles returns information about markdown files from paths, without reading them.
+func (f *fileFilter) statMarkdownFiles(paths []string) (map[string]fs.FileInfo, error) {
+	result := make(map[string]fs.FileInfo)
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case info.IsDir():
+			files, err := f.findDirFiles(absPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, filename := range files {
+				info, err := os.Stat(filename)
+				if err != nil {
+					return nil, err
+				}
+				result[filename] = info
+			}
+		case f.isIncludedFile(absPath):
+			result[absPath] = info
+		}
+	}
+	return result, nil
+}
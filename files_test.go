@@ -2,6 +2,10 @@ package main
 
 import (
 	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"testing/fstest"
 )
@@ -23,13 +27,94 @@ func TestIsMarkdownFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := isMarkdownFile(tt.path); got != tt.want {
+			var ff fileFilter
+			if got := ff.isMarkdownFile(tt.path); got != tt.want {
 				t.Errorf("isMarkdownFile(%q) = %v, want %v", tt.path, got, tt.want)
 			}
 		})
 	}
 }
 
+func TestFileFlags(t *testing.T) {
+	var ff fileFilter
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fileFlags(fs, &ff)
+	err := fs.Parse([]string{"-ext", "md, .markdown,TXT", "-include", "**/*-tasks.*", "-include", "Projects/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.md", false},
+		{"a-tasks.md", true},
+		{"notes/b-tasks.markdown", true},
+		{"./notes/c-tasks.txt", true},
+		{"notes/d-tasks.mdx", false},
+		{"Projects/x/e.MD", true},
+		{"Projects/x/e.pdf", false},
+		{"notes/Projects/f.md", false},
+	}
+	for _, tt := range tests {
+		if got := ff.isIncluded(tt.path); got != tt.want {
+			t.Errorf("isIncluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	for path, want := range map[string]bool{
+		"/home/user/vault/Projects/a.md": true,
+		"vault/Projects/x/b.md":          true,
+		"vault/notes/c.md":               false,
+		"vault/notes/d-tasks.md":         true,
+		"/Projects/e.md":                 true,
+	} {
+		if got := ff.isIncludedFile(filepath.FromSlash(path)); got != want {
+			t.Errorf("isIncludedFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"Projects/a.md", "notes/b.md"} {
+		name = filepath.Join(dir, "vault", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vault := filepath.Join(dir, "vault")
+	walked, err := ff.listMarkdownFiles([]string{vault})
+	if err != nil {
+		t.Fatal(err)
+	}
+	explicit, err := ff.listMarkdownFiles([]string{filepath.Join(vault, "Projects", "a.md"), filepath.Join(vault, "notes", "b.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(vault, "Projects", "a.md")}; !slices.Equal(walked, want) || !slices.Equal(explicit, want) {
+		t.Errorf("listMarkdownFiles() = %q (walked), %q (explicit), want %q", walked, explicit, want)
+	}
+
+	fsys := fstest.MapFS{
+		"Projects/a.md":        &fstest.MapFile{},
+		"notes/Projects/b.md":  &fstest.MapFile{},
+		"notes/c-tasks.txt":    &fstest.MapFile{},
+		"notes/d.markdown":     &fstest.MapFile{},
+		"notes/sub/e-tasks.md": &fstest.MapFile{},
+	}
+	got, err := ff.findMarkdownFiles(fsys, "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"notes/Projects/b.md", "notes/c-tasks.txt", "notes/sub/e-tasks.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("findMarkdownFiles() = %q, want %q", got, want)
+	}
+}
+
 func TestReadFiles(t *testing.T) {
 	// Create a virtual filesystem for testing.
 	fsys := fstest.MapFS{
@@ -100,7 +185,8 @@ func TestReadFiles(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := readMarkdownFilesFromFS(fsys, tt.paths)
+			var ff fileFilter
+			got, err := ff.readMarkdownFilesFromFS(fsys, tt.paths)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("readFiles() error = %v, wantErr %v", err, tt.wantErr)
 				return
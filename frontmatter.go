@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// noteSettingsKey is a key in YAML frontmatter of a note with settings for its tasks.
+const noteSettingsKey = "tasks-notify"
+
+// noteSettings are settings for all tasks in a note, given in its frontmatter:
+//
+//	---
+//	tasks-notify:
+//	  recipients: [alice@example.com]
+//	  priority: high
+//	  skip: false
+//	  default-due: 2024-02-01
+//	  tags: [project]
+//	---
+type noteSettings struct {
+	Recipients []string `json:"recipients,omitempty" yaml:"recipients"`  // Send tasks to these addresses instead of -email.
+	Priority   string   `json:"priority,omitempty"   yaml:"priority"`    // Priority of tasks without own priority.
+	Skip       bool     `json:"skip,omitempty"       yaml:"skip"`        // Do not notify about tasks.
+	DefaultDue string   `json:"-"                    yaml:"default-due"` // Due date of tasks without dates.
+	Tags       []string `json:"tags,omitempty"       yaml:"tags"`        // Tags added to tasks (with #).
+
+	defaultDue time.Time
+	err        error // Reason why invalid settings were replaced by zero settings.
+}
+
+// noteSettingsOf returns settings from frontmatter metadata stored in the parser
+// context. Invalid settings result in zero settings with err set.
+func noteSettingsOf(pc parser.Context) noteSettings {
+	metadata, err := meta.TryGet(pc)
+	if err != nil {
+		return noteSettings{err: fmt.Errorf("parse frontmatter: %w", err)}
+	}
+	s, err := parseNoteSettings(metadata)
+	if err != nil {
+		return noteSettings{err: err}
+	}
+	return s
+}
+
+// parseNoteSettings returns settings from frontmatter metadata.
+// Metadata without settings results in zero settings.
+func parseNoteSettings(metadata map[string]any) (noteSettings, error) {
+	value, ok := metadata[noteSettingsKey]
+	if !ok || value == nil {
+		return noteSettings{}, nil
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return noteSettings{}, err
+	}
+	var s noteSettings
+	err = yaml.Unmarshal(data, &s)
+	if err != nil {
+		return noteSettings{}, err
+	}
+	for i, recipient := range s.Recipients {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return noteSettings{}, fmt.Errorf("bad recipient %q: %w", recipient, err)
+		}
+		s.Recipients[i] = addr.Address
+	}
+	s.Priority = strings.ToLower(s.Priority)
+	if s.Priority != "" && priorityIndex(s.Priority) < 0 {
+		return noteSettings{}, fmt.Errorf("bad priority %q", s.Priority)
+	}
+	if s.DefaultDue != "" {
+		s.defaultDue, err = time.Parse(time.DateOnly, s.DefaultDue)
+		if err != nil {
+			return noteSettings{}, fmt.Errorf("bad default-due: %w", err)
+		}
+	}
+	for i, tag := range s.Tags {
+		if tag == "" || strings.ContainsAny(tag, " \t") {
+			return noteSettings{}, fmt.Errorf("bad tag %q", tag)
+		}
+		if !strings.HasPrefix(tag, "#") {
+			s.Tags[i] = "#" + tag
+		}
+	}
+	return s, nil
+}
+
+// apply sets default due date of tasks without dates.
+func (s noteSettings) apply(tasks []parsedTask) {
+	if s.defaultDue.IsZero() {
+		return
+	}
+	for i := range tasks {
+		t := &tasks[i].Task
+		if t.Due.IsZero() && t.Scheduled.IsZero() && t.Start.IsZero() {
+			t.Due = s.defaultDue
+		}
+	}
+}
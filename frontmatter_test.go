@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNoteSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    noteSettings
+		wantErr bool
+	}{
+		{"No frontmatter", "- [ ] Task\n", noteSettings{}, false},
+		{"Not at start", "\n---\ntasks-notify:\n  skip: true\n---\n", noteSettings{}, false},
+		{"Other keys", "---\ntags: [a]\n---\n", noteSettings{}, false},
+		{
+			"All keys",
+			"---\ntitle: Project\ntasks-notify:\n  recipients: [alice@example.com, Bob <bob@example.com>]\n" +
+				"  priority: High\n  skip: true\n  default-due: 2024-02-01\n  tags: [work, '#project']\n---\n- [ ] Task\n",
+			noteSettings{
+				Recipients: []string{"alice@example.com", "bob@example.com"},
+				Priority:   "high",
+				Skip:       true,
+				DefaultDue: "2024-02-01",
+				Tags:       []string{"#work", "#project"},
+				defaultDue: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+			false,
+		},
+		{"Flow style", "---\r\ntasks-notify: { skip: true }\r\n---\r\n", noteSettings{Skip: true}, false},
+		{"Bad YAML", "---\ntasks-notify: [\n---\n", noteSettings{}, true},
+		{"Bad recipient", "---\ntasks-notify:\n  recipients: [alice]\n---\n", noteSettings{}, true},
+		{"Bad priority", "---\ntasks-notify:\n  priority: urgent\n---\n", noteSettings{}, true},
+		{"Bad default-due", "---\ntasks-notify:\n  default-due: tomorrow\n---\n", noteSettings{}, true},
+		{"Bad tag", "---\ntasks-notify:\n  tags: ['a b']\n---\n", noteSettings{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := parseTasks(newMarkdown(), &tasksConfig{}, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got.err != nil) != tt.wantErr {
+				t.Fatalf("parseTasks() settings error = %v, wantErr %v", got.err, tt.wantErr)
+			}
+			if !slices.Equal(got.Recipients, tt.want.Recipients) || got.Priority != tt.want.Priority ||
+				got.Skip != tt.want.Skip || got.DefaultDue != tt.want.DefaultDue ||
+				!slices.Equal(got.Tags, tt.want.Tags) || !got.defaultDue.Equal(tt.want.defaultDue) {
+				t.Errorf("parseTasks() settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoteSettings(t *testing.T) {
+	files := map[string][]byte{
+		"project.md": []byte(`---
+tasks-notify:
+  priority: high
+  default-due: 2024-01-15
+  tags: [project]
+---
+- [ ] Undated #work
+- [ ] Later 📅 2024-01-20
+- [ ] Lowest ⏬
+`),
+		"skipped.md": []byte(`---
+tasks-notify:
+  skip: true
+---
+- [ ] Skipped 📅 2024-01-15
+- [ ] Blocker 🆔 abc
+`),
+		"blocked.md": []byte("- [ ] Blocked ⛔ abc 📅 2024-01-15\n"),
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	var matched []Task
+	var notes []noteSettings
+	opts := &filterOptions{Now: now, OnMatch: func(_ string, note noteSettings, task Task, _ int) {
+		matched = append(matched, task)
+		notes = append(notes, note)
+	}}
+	tasks, _, err := filterMarkdownFiles(opts, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || string(tasks["project.md"]) != "- [ ] Undated #work\n- [ ] Lowest ⏬\n" {
+		t.Errorf("filterMarkdownFiles() = %q", tasks)
+	}
+	if len(matched) != 2 {
+		t.Fatalf("matched = %+v", matched)
+	}
+	if got := priorities[taskPriority(matched[0], notes[0])].Name; got != "high" {
+		t.Errorf("taskPriority(%q) = %q, want high", matched[0].Line, got)
+	}
+	if got := priorities[taskPriority(matched[1], notes[1])].Name; got != "lowest" {
+		t.Errorf("taskPriority(%q) = %q, want lowest", matched[1].Line, got)
+	}
+	if got, want := taskTags(matched[0], notes[0]), []string{"#work", "#project"}; !slices.Equal(got, want) {
+		t.Errorf("taskTags(%q) = %q, want %q", matched[0].Line, got, want)
+	}
+}
+
+func TestRunRecipients(t *testing.T) {
+	dir := t.TempDir()
+	today := time.Now().Format(time.DateOnly)
+	files := map[string]string{
+		"project.md": "---\ntasks-notify:\n  recipients: [alice@example.com, Bob <bob@example.com>]\n---\n- [ ] Project task 📅 " + today + "\n",
+		"other.md":   "- [ ] Other task 📅 " + today + "\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Queued email is due on each retry and fails, so it must be retried once per run.
+	outbox := NewOutbox(t.TempDir(), 365*24*time.Hour)
+	now := time.Now()
+	outbox.Now = func() time.Time { now = now.Add(24 * time.Hour); return now }
+	err := outbox.Put("test@example.com", []string{"queued@example.com"}, []byte("queued"), ErrMock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(map[string]string)
+	attempts := make(map[string]int)
+	emailCfg := &EmailConfig{
+		Host: "localhost",
+		Port: 25,
+		From: "test@example.com",
+		SendMail: func(_ string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+			addr := strings.Join(to, ",")
+			attempts[addr]++
+			if addr == "queued@example.com" {
+				return &textproto.Error{Code: 451, Msg: "try again later"}
+			}
+			sent[addr] = string(msg)
+			return nil
+		},
+		Outbox: outbox,
+	}
+	emailTo := "me@example.com"
+	var stdout strings.Builder
+	err = run(&filterOptions{Now: time.Now()}, &emailTo, emailCfg, &stdout, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"alice@example.com": "Project task",
+		"bob@example.com":   "Project task",
+		"me@example.com":    "Other task",
+	}
+	if len(sent) != len(want) {
+		t.Errorf("sent to %d addresses, want %d: %q", len(sent), len(want), sent)
+	}
+	for to, task := range want {
+		msg := sent[to]
+		if !strings.Contains(msg, task) || strings.Count(msg, " task ") != 1 {
+			t.Errorf("email to %s =\n%s\nwant only %q", to, msg, task)
+		}
+	}
+	if attempts["queued@example.com"] != 1 {
+		t.Errorf("queued email was retried %d times, want 1", attempts["queued@example.com"])
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/powerman/goldmark-obsidian/obsast"
+)
+
+const (
+	icsMaxLineLen = 75 // Octets, without CRLF (RFC 5545 section 3.1).
+	icsDateFormat = "20060102"
+)
+
+var (
+	reTaskAssignee = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.-]*[\p{L}\p{N}_])`)
+	reTaskPriority = regexp.MustCompile(`[🔺⏫🔼🔽⏬]\x{FE0F}?`)
+	reSpaces       = regexp.MustCompile(`\s+`)
+
+	icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+
+	// Priority in iCalendar for each of priorities (1 is the highest, 0 is undefined).
+	icsPriorities = []int{1, 3, 5, 0, 7, 9}
+)
+
+// taskAssignees returns names (without @) mentioned in the task line.
+func taskAssignees(line string) []string {
+	var names []string
+	for _, m := range reTaskAssignee.FindAllStringSubmatch(line, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// taskSummary returns task line without checkbox, dates, recurrence, priority, IDs and block ID
+// (in emoji and Dataview formats).
+func taskSummary(task Task) string {
+	line := task.Line
+	if i := strings.Index(line, "🔁"); i >= 0 {
+		rest := strings.TrimLeft(line[i+len("🔁"):], " \t")
+		line = line[:i] + " " + strings.TrimPrefix(rest, task.Recurrence)
+	}
+	line = reTaskCheckbox.ReplaceAllString(line, "")
+	line = reTaskBlockID.ReplaceAllString(line, "")
+	line = reTaskDate.ReplaceAllString(line, "")
+	line = reTaskID.ReplaceAllString(line, "")
+	line = reTaskDependsOn.ReplaceAllString(line, "")
+	line = reTaskPriority.ReplaceAllString(line, "")
+	line = reDataviewField.ReplaceAllString(line, "")
+	return strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+}
+
+// ServeCalendar returns iCalendar feed with not done tasks which have due or scheduled date.
+//
+// Supported query parameters:
+//   - token: must be equal to d.CalendarToken
+//   - tag: only tasks with this tag
+//   - assignee: only tasks mentioning @assignee
+//   - type: event (default, all-day VEVENT on due or scheduled date) or todo (VTODO)
+func (d *Dashboard) ServeCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	token := q.Get("token")
+	if d.CalendarToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.CalendarToken)) != 1 {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	tag := q.Get("tag")
+	if tag != "" && !strings.HasPrefix(tag, "#") {
+		tag = "#" + tag
+	}
+	assignee := strings.TrimPrefix(q.Get("assignee"), "@")
+	component := "VEVENT"
+	switch strings.ToLower(q.Get("type")) {
+	case "", "event":
+	case "todo":
+		component = "VTODO"
+	default:
+		http.Error(w, "type must be event or todo", http.StatusBadRequest)
+		return
+	}
+
+	files, err := parseMarkdownPaths(&d.Config, d.Paths, d.MaxFileSize)
+	if err != nil {
+		log.Println("Warning: Failed to load tasks:", err)
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	tasks := collectICSTasks(files, func(task Task, note noteSettings) bool {
+		switch {
+		case !isOpen(task), task.Due.IsZero() && task.Scheduled.IsZero():
+			return false
+		case tag != "" && !slices.ContainsFunc(taskTags(task, note), func(t string) bool { return strings.EqualFold(t, tag) }):
+			return false
+		case assignee != "" && !slices.ContainsFunc(taskAssignees(task.Line), func(a string) bool { return strings.EqualFold(a, assignee) }):
+			return false
+		}
+		return true
+	})
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	err = writeICS(w, component, d.Now(), tasks)
+	if err != nil {
+		log.Println("Warning: Failed to send response:", err)
+	}
+}
+
+type icsTask struct {
+	UID  string // Without domain part.
+	File string
+	Line int
+	Task Task
+	Note noteSettings // Settings of the note containing the task.
+}
+
+// collectICSTasks returns tasks of files (except skipped ones) accepted by match, with unique UIDs.
+func collectICSTasks(files map[string]parsedFile, match func(Task, noteSettings) bool) []icsTask {
+	var tasks []icsTask
+	seen := make(map[string]int)
+	for _, filename := range slices.Sorted(maps.Keys(files)) {
+		file := files[filename]
+		if file.Settings.Skip {
+			continue
+		}
+		for _, t := range file.Tasks {
+			if !match(t.Task, file.Settings) {
+				continue
+			}
+			uid := icsUID(filename, t.Task)
+			seen[uid]++
+			if n := seen[uid]; n > 1 {
+				uid = fmt.Sprintf("%s-%d", uid, n)
+			}
+			tasks = append(tasks, icsTask{UID: uid, File: filename, Line: t.Line, Task: t.Task, Note: file.Settings})
+		}
+	}
+	return tasks
+}
+
+// icsUID returns UID which does not change while task's ID or file and text
+// (without status, dates and other fields, see taskSummary) are not changed.
+func icsUID(file string, task Task) string {
+	if task.ID != "" {
+		return task.ID
+	}
+	sum := sha256.Sum256([]byte(file + "\x00" + taskSummary(task)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// writeICS writes tasks as iCalendar with all-day components of given type (VEVENT or VTODO).
+func writeICS(w io.Writer, component string, now time.Time, tasks []icsTask) error {
+	iw := &icsWriter{w: w}
+	iw.prop("BEGIN", "VCALENDAR")
+	iw.prop("VERSION", "2.0")
+	iw.prop("PRODID", "-//powerman//md-tasks-notify//EN")
+	iw.prop("CALSCALE", "GREGORIAN")
+	iw.prop("X-WR-CALNAME", "Tasks")
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, t := range tasks {
+		task := t.Task
+		iw.prop("BEGIN", component)
+		iw.prop("UID", t.UID+"@md-tasks-notify")
+		iw.prop("DTSTAMP", stamp)
+		iw.prop("SUMMARY", icsEscaper.Replace(taskSummary(task)))
+		iw.prop("DESCRIPTION", icsEscaper.Replace(fmt.Sprintf("%s:%d", t.File, t.Line)))
+		open := url.URL{Scheme: "obsidian", Host: "open", RawQuery: url.Values{"path": {t.File}}.Encode()}
+		iw.prop("URL", open.String())
+		if p := icsPriorities[taskPriority(task, t.Note)]; p != 0 {
+			iw.prop("PRIORITY", fmt.Sprint(p))
+		}
+		if tags := taskTags(task, t.Note); len(tags) > 0 {
+			for i := range tags {
+				tags[i] = icsEscaper.Replace(strings.TrimPrefix(tags[i], "#"))
+			}
+			iw.prop("CATEGORIES", strings.Join(tags, ","))
+		}
+		date := task.Due
+		if date.IsZero() {
+			date = task.Scheduled
+		}
+		if component == "VTODO" {
+			if !task.Start.IsZero() && (date.IsZero() || !task.Start.After(date)) {
+				iw.prop("DTSTART;VALUE=DATE", task.Start.Format(icsDateFormat))
+			}
+			if !date.IsZero() {
+				iw.prop("DUE;VALUE=DATE", date.Format(icsDateFormat))
+			}
+			status := "NEEDS-ACTION"
+			if task.StatusType == obsast.PlugTasksStatusTypeInProgress {
+				status = "IN-PROCESS"
+			}
+			iw.prop("STATUS", status)
+		} else {
+			iw.prop("DTSTART;VALUE=DATE", date.Format(icsDateFormat))
+			iw.prop("DTEND;VALUE=DATE", date.AddDate(0, 0, 1).Format(icsDateFormat))
+			iw.prop("TRANSP", "TRANSPARENT")
+		}
+		iw.prop("END", component)
+	}
+	iw.prop("END", "VCALENDAR")
+	return iw.err
+}
+
+// icsWriter writes content lines folded to icsMaxLineLen and remembers first error.
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icsWriter) prop(name, value string) {
+	if iw.err != nil {
+		return
+	}
+	line := name + ":" + value
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > icsMaxLineLen {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	_, iw.err = io.WriteString(iw.w, b.String())
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskSummary(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"[ ] Simple", "Simple"},
+		{"[/] Call @bob, about #work ⏫ 🔁 every week 📅 2024-01-15 ⏳ 2024-01-14 🆔 call ⛔ a, b ^blk", "Call @bob, about #work"},
+		{"[ ] Release 🛫 2024-01-01 ➕ 2023-12-31 🔽", "Release"},
+		{"[ ] Review every week 🔁every week", "Review every week"},
+	}
+	for _, tc := range tests {
+		tasks, _, err := parseTasks(newMarkdown(), &tasksConfig{}, []byte("- "+tc.line))
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("parseTasks(%q) = %v, %v", tc.line, tasks, err)
+		}
+		if got := taskSummary(tasks[0]); got != tc.want {
+			t.Errorf("taskSummary(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestServeCalendar(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.md")
+	err := os.WriteFile(file, []byte(`- [ ] Report, draft @alice #work ⏫ 📅 2024-01-15 🆔 report
+- [/] Review @bob #work 🛫 2024-01-10 ⏳ 2024-01-16
+- [ ] No date @alice
+- [x] Done @alice 📅 2024-01-15
+- [ ] Very long task name which must be folded because it does not fit into seventy five octets 📅 2024-02-01
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range map[string]string{
+		"b.md": "---\ntasks-notify:\n  default-due: 2024-01-20\n---\n- [ ] Default due\n",
+		"c.md": "---\ntasks-notify:\n  skip: true\n---\n- [ ] Skipped 📅 2024-01-15\n",
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(source), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	d := &Dashboard{Paths: []string{dir}, Window: "today", CalendarToken: "secret", Now: func() time.Time { return now }}
+	mux := newServeMux(d)
+
+	reSummary := regexp.MustCompile(`(?m)^SUMMARY:(.*)\r$`)
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       []string // SUMMARY values.
+		wantLines  []string
+	}{
+		{query: "", wantStatus: http.StatusForbidden},
+		{query: "?token=wrong", wantStatus: http.StatusForbidden},
+		{query: "?token=secret&type=journal", wantStatus: http.StatusBadRequest},
+		{
+			query:      "?token=secret",
+			wantStatus: http.StatusOK,
+			want: []string{
+				`Report\, draft @alice #work`,
+				"Review @bob #work",
+				"Very long task name which must be folded because it does not fit in",
+				"Default due",
+			},
+			wantLines: []string{
+				"BEGIN:VEVENT",
+				"UID:report@md-tasks-notify",
+				"DTSTAMP:20240115T090000Z",
+				"PRIORITY:3",
+				"CATEGORIES:work",
+				"DTSTART;VALUE=DATE:20240115",
+				"DTEND;VALUE=DATE:20240116",
+				"DTSTART;VALUE=DATE:20240116",
+				" to seventy five octets",
+				"DTSTART;VALUE=DATE:20240120",
+			},
+		},
+		{
+			query:      "?token=secret&assignee=@ALICE",
+			wantStatus: http.StatusOK,
+			want:       []string{`Report\, draft @alice #work`},
+		},
+		{
+			query:      "?token=secret&tag=work&type=todo",
+			wantStatus: http.StatusOK,
+			want:       []string{`Report\, draft @alice #work`, "Review @bob #work"},
+			wantLines: []string{
+				"BEGIN:VTODO",
+				"DUE;VALUE=DATE:20240115",
+				"STATUS:NEEDS-ACTION",
+				"DTSTART;VALUE=DATE:20240110",
+				"DUE;VALUE=DATE:20240116",
+				"STATUS:IN-PROCESS",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calendar.ics"+tc.query, nil))
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			var got []string
+			for _, m := range reSummary.FindAllStringSubmatch(body, -1) {
+				got = append(got, m[1])
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("summaries:\n got %q\nwant %q", got, tc.want)
+			}
+			lines := strings.Split(body, "\r\n")
+			for _, want := range tc.wantLines {
+				if !strings.Contains("\n"+strings.Join(lines, "\n")+"\n", "\n"+want+"\n") {
+					t.Errorf("missing line %q in:\n%s", want, body)
+				}
+			}
+			for _, line := range lines {
+				if len(line) > icsMaxLineLen {
+					t.Errorf("line is too long: %q", line)
+				}
+			}
+			if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+				t.Errorf("bad calendar:\n%s", body)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		d := &Dashboard{Paths: []string{dir}, Window: "today", Now: func() time.Time { return now }}
+		w := httptest.NewRecorder()
+		newServeMux(d).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calendar.ics?token=", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Files with gitignore-like patterns honored in each directory.
+var ignoreFiles = []string{".gitignore", ".mdtasksignore"}
+
+const obsidianAppConfig = ".obsidian/app.json"
+
+// defaultIgnorePatterns skip hidden directories (like .obsidian, .trash and .git) and node_modules.
+var defaultIgnorePatterns = []string{".*/", "node_modules/"}
+
+// ignoreRule matches paths relative to the root directory given to newIgnoreRules.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	prefix  bool // Directories are matched with trailing slash.
+}
+
+// ignoreRules decides which files and directories are skipped while searching
+// for Markdown files in a directory. Later rules override earlier ones, like in .gitignore.
+type ignoreRules struct {
+	fsys   fs.FS
+	root   string // Rules are relative to root: a vault containing dir or dir itself.
+	dir    string // Searched directory relative to root, it is never skipped.
+	rules  []ignoreRule
+	loaded map[string]bool // Directories with loaded ignore files.
+}
+
+// newIgnoreRules returns rules for searching dir (relative to root in fsys):
+// default rules, rules from exclude patterns (see fileFilter) relative to dir
+// and from Obsidian's "Excluded files" setting (if root is a vault).
+// Rules from ignore files are added by walk and ignored.
+func newIgnoreRules(fsys fs.FS, root, dir string, exclude []string) *ignoreRules {
+	ir := &ignoreRules{fsys: fsys, root: root, dir: dir, loaded: make(map[string]bool)}
+	for _, pattern := range defaultIgnorePatterns {
+		ir.add(".", pattern)
+	}
+	for _, pattern := range exclude {
+		ir.add(dir, pattern)
+	}
+	ir.loadObsidian()
+	return ir
+}
+
+// loadObsidian adds rules from userIgnoreFilters in Obsidian config.
+// Filters are path prefixes or (if enclosed in slashes) regular expressions.
+func (ir *ignoreRules) loadObsidian() {
+	data, err := fs.ReadFile(ir.fsys, path.Join(ir.root, obsidianAppConfig))
+	if err != nil {
+		return
+	}
+	var cfg struct {
+		UserIgnoreFilters []string `json:"userIgnoreFilters"`
+	}
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to parse %q: %v", path.Join(ir.root, obsidianAppConfig), err)
+		return
+	}
+	for _, filter := range cfg.UserIgnoreFilters {
+		expr := "^" + regexp.QuoteMeta(filter)
+		if len(filter) > 2 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/") {
+			expr = filter[1 : len(filter)-1]
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("Warning: Skipping Obsidian excluded files filter %q: %v", filter, err)
+			continue
+		}
+		ir.rules = append(ir.rules, ignoreRule{re: re, prefix: true})
+	}
+}
+
+// loadDir adds rules from ignore files in dir (relative to root) if they were not added yet.
+func (ir *ignoreRules) loadDir(dir string) {
+	if ir.loaded[dir] {
+		return
+	}
+	ir.loaded[dir] = true
+	for _, name := range ignoreFiles {
+		data, err := fs.ReadFile(ir.fsys, path.Join(ir.root, dir, name))
+		if err != nil {
+			continue
+		}
+		for line := range strings.SplitSeq(string(data), "\n") {
+			ir.add(dir, line)
+		}
+	}
+}
+
+// add adds rule with gitignore pattern relative to dir.
+func (ir *ignoreRules) add(dir, pattern string) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+	var rule ignoreRule
+	if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+		rule.negate, pattern = true, rest
+	}
+	pattern = strings.TrimPrefix(pattern, `\`) // Escaped leading "#" or "!".
+	if rest, ok := strings.CutSuffix(pattern, "/"); ok {
+		rule.dirOnly, pattern = true, rest
+	}
+	re, err := compileGlob(dir, pattern)
+	if err != nil {
+		log.Printf("Warning: Skipping bad ignore pattern %q: %v", pattern, err)
+		return
+	}
+	rule.re = re
+	ir.rules = append(ir.rules, rule)
+}
+
+// compileGlob returns regular expression matching paths (relative to root)
+// matched by gitignore glob pattern (without "!" and trailing "/") relative to dir.
+func compileGlob(dir, pattern string) (*regexp.Regexp, error) {
+	prefix := "(?:.*/)?" // Pattern without slash matches at any level.
+	if strings.Contains(pattern, "/") {
+		prefix, pattern = "", strings.TrimPrefix(pattern, "/")
+	}
+	if dir != "." {
+		prefix = regexp.QuoteMeta(dir+"/") + prefix
+	}
+	return regexp.Compile("^" + prefix + globToRegexp(pattern) + "$")
+}
+
+// globToRegexp converts gitignore glob (with **) to regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += 1 + end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// match reports whether name (relative to root, not root itself) must be skipped.
+// It does not check parent directories.
+func (ir *ignoreRules) match(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ir.rules {
+		if (!rule.dirOnly || isDir) && (rule.re.MatchString(name) || (rule.prefix && isDir && rule.re.MatchString(name+"/"))) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// walk is like fs.WalkDir for ir.dir, but skips ignored files and directories.
+// Ignore files in root and parent directories of ir.dir are also used.
+// Paths given to fn are relative to fsys, like in fs.WalkDir.
+func (ir *ignoreRules) walk(fn fs.WalkDirFunc) error {
+	return ir.walkDir(ir.dir, fn)
+}
+
+// walkDir is like walk for dir (relative to root) inside ir.dir, which is not
+// skipped even if it is ignored.
+func (ir *ignoreRules) walkDir(dir string, fn fs.WalkDirFunc) error {
+	for _, parent := range parentDirs(dir) {
+		ir.loadDir(parent)
+	}
+	return fs.WalkDir(ir.fsys, path.Join(ir.root, dir), func(p string, d fs.DirEntry, err error) error {
+		rel := ir.rel(p)
+		if err == nil && rel != dir && ir.match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if err == nil && d.IsDir() {
+			ir.loadDir(rel)
+		}
+		return fn(p, d, err)
+	})
+}
+
+// rel returns path p (relative to fsys) relative to root.
+func (ir *ignoreRules) rel(p string) string {
+	if ir.root == "." {
+		return p
+	}
+	if p == ir.root {
+		return "."
+	}
+	return strings.TrimPrefix(p, ir.root+"/")
+}
+
+// relDir returns path p (relative to fsys) relative to ir.dir.
+func (ir *ignoreRules) relDir(p string) string {
+	rel := ir.rel(p)
+	if ir.dir == "." {
+		return rel
+	}
+	if rel == ir.dir {
+		return "."
+	}
+	return strings.TrimPrefix(rel, ir.dir+"/")
+}
+
+// ignored reports whether file or directory name (relative to root) inside
+// ir.dir is skipped while searching for Markdown files in ir.dir.
+func (ir *ignoreRules) ignored(name string, isDir bool) bool {
+	for _, parent := range parentDirs(name) {
+		if ir.isBelowDir(parent) && ir.match(parent, true) {
+			return true
+		}
+		ir.loadDir(parent)
+	}
+	return ir.isBelowDir(name) && ir.match(name, isDir)
+}
+
+// isBelowDir reports whether name (relative to root) is inside ir.dir (but is not ir.dir).
+func (ir *ignoreRules) isBelowDir(name string) bool {
+	return name != ir.dir && (ir.dir == "." || strings.HasPrefix(name, ir.dir+"/"))
+}
+
+// parentDirs returns parent directories of name (relative to root), starting with root.
+func parentDirs(name string) []string {
+	dirs := []string{"."}
+	for i := range len(name) {
+		if name[i] == '/' {
+			dirs = append(dirs, name[:i])
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFindMarkdownFilesIgnore(t *testing.T) {
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+	fsys := fstest.MapFS{
+		"a.md":                          file(""),
+		"draft.md":                      file(""),
+		"keep-draft.md":                 file(""),
+		".gitignore":                    file("# Comment\ndraft*.md\n!keep-draft.md\n/build/\n"),
+		".obsidian/app.json":            file(`{"userIgnoreFilters":["Templates/","/^Archive/\\d{4}/"]}`),
+		".obsidian/plugins/x/README.md": file(""),
+		".trash/old.md":                 file(""),
+		"node_modules/pkg/README.md":    file(""),
+		"build/out.md":                  file(""),
+		"notes/build/b.md":              file(""),
+		"notes/.mdtasksignore":          file("private/\n*.tmp.md\n"),
+		"notes/private/secret.md":       file(""),
+		"notes/x.tmp.md":                file(""),
+		"notes/sub/draft.md":            file(""),
+		"private/c.md":                  file(""),
+		"Templates/daily.md":            file(""),
+		"Templates.md":                  file(""),
+		"Archive/2023/d.md":             file(""),
+		"Archive/e.md":                  file(""),
+		"tmp/f.md":                      file(""),
+		"docs/g.md":                     file(""),
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		exclude []string
+		want    []string
+	}{
+		{
+			name: "root",
+			dir:  ".",
+			want: []string{
+				"Archive/e.md", "Templates.md", "a.md", "docs/g.md", "keep-draft.md",
+				"notes/build/b.md", "private/c.md", "tmp/f.md",
+			},
+		},
+		{
+			name:    "exclude",
+			dir:     ".",
+			exclude: []string{"tmp/", "docs/**", "a.md"},
+			want: []string{
+				"Archive/e.md", "Templates.md", "keep-draft.md", "notes/build/b.md", "private/c.md",
+			},
+		},
+		{
+			name: "subdir",
+			dir:  "notes",
+			want: []string{"notes/build/b.md", "notes/sub/draft.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff := &fileFilter{Exclude: tt.exclude}
+			got, err := ff.findMarkdownFiles(fsys, tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findMarkdownFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesIgnored(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":       &fstest.MapFile{Data: []byte("drafts/\n")},
+		"a/.mdtasksignore": &fstest.MapFile{Data: []byte("*.tmp.md\n")},
+	}
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"a.md", false, false},
+		{"drafts/a.md", false, true},
+		{"x/drafts/a.md", false, true},
+		{"a/b.tmp.md", false, true},
+		{"b.tmp.md", false, false},
+		{".obsidian/a.md", false, true},
+		{".new", true, true},
+		{".new", false, false},
+	}
+	for _, tt := range tests {
+		if got := newIgnoreRules(fsys, ".", ".", nil).ignored(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestListMarkdownFilesInVault(t *testing.T) {
+	vault := t.TempDir()
+	for name, data := range map[string]string{
+		".obsidian/app.json":    `{"userIgnoreFilters":["Projects/Archive/"]}`,
+		".gitignore":            "draft*.md\n",
+		"Projects/a.md":         "",
+		"Projects/draft.md":     "",
+		"Projects/Archive/b.md": "",
+		"Projects/tmp/c.md":     "",
+		"Projects/x/tmp/d.md":   "",
+		"tmp/e.md":              "",
+	} {
+		name = filepath.Join(vault, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(name), 0o700)
+		if err == nil {
+			err = os.WriteFile(name, []byte(data), 0o600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ff := &fileFilter{Exclude: []string{"/tmp/"}}
+	dir := filepath.Join(vault, "Projects")
+	got, err := ff.listMarkdownFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "x", "tmp", "d.md")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listMarkdownFiles() = %q, want %q", got, want)
+	}
+	stat, err := ff.statMarkdownFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(slices.Sorted(maps.Keys(stat)), want) {
+		t.Errorf("statMarkdownFiles() = %q, want %q", slices.Sorted(maps.Keys(stat)), want)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	errBadFormat = errors.New("bad format")
+
+	reTaskCheckbox = regexp.MustCompile(`^\[(.)\]\s`)
+	reTaskDate     = regexp.MustCompile(`(📅|⏳|🛫|➕|✅|❌)\x{FE0F}?\s*(\S*)`)
+)
+
+// knownStatusSymbols are status symbols supported by default by Obsidian Tasks plugin,
+// custom status symbols are loaded into tasksConfig.Statuses.
+var knownStatusSymbols = map[string]bool{" ": true, "x": true, "X": true, "/": true, "-": true}
+
+// lintProblem is a problem found in a task.
+type lintProblem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (p lintProblem) String() string {
+	file := p.File
+	if file == "" {
+		file = "-"
+	}
+	return fmt.Sprintf("%s:%d: %s", file, p.Line, p.Message)
+}
+
+// lintMain implements "lint" command and returns exit code.
+func lintMain(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s lint [flags] [PATH ...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(fs.Output(), "Report problems in tasks, exit with status 1 if any found.")
+		_, _ = fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "Output format: text or json")
+	var cfg config
+	fileFlags(fs, &cfg.Files)
+	_ = fs.Parse(args)
+	cfg.Tasks.load(fs.Args())
+
+	n, err := runLint(&cfg, *format, os.Stdout, fs.Args())
+	if err != nil {
+		log.Fatalln("Failed to", err)
+	}
+	if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runLint outputs problems found in files at paths (selected by cfg) in given format
+// and returns amount of problems.
+func runLint(cfg *config, format string, stdout io.Writer, paths []string) (int, error) {
+	if format != "text" && format != "json" {
+		return 0, fmt.Errorf("lint: %w %q", errBadFormat, format)
+	}
+	files, err := cfg.Files.readMarkdownFilesOrStdin(paths)
+	if err != nil {
+		return 0, err
+	}
+	problems, err := lintFiles(&cfg.Tasks, files)
+	if err != nil {
+		return 0, fmt.Errorf("lint: %w", err)
+	}
+
+	switch format {
+	case "json":
+		if problems == nil {
+			problems = []lintProblem{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(problems)
+	default:
+		for _, p := range problems {
+			if _, err = fmt.Fprintln(stdout, p); err != nil {
+				break
+			}
+		}
+	}
+	return len(problems), err
+}
+
+// lintFiles returns problems found in tasks, sorted by file and line.
+func lintFiles(cfg *tasksConfig, files map[string][]byte) ([]lintProblem, error) {
+	var problems []lintProblem
+	ids := make(map[string]lintProblem) // Location of the task with ID.
+	graph := make(map[string][]string)  // Task ID -> IDs of tasks it depends on.
+	var dependents []lintProblem
+	var dependsOn [][]string
+
+	md := newMarkdown()
+	for _, filename := range slices.Sorted(maps.Keys(files)) {
+		data := files[filename]
+		items, err := parseListItems(md, cfg, data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %w", filename, err)
+		}
+		for _, task := range items {
+			m := reTaskCheckbox.FindStringSubmatch(task.Line)
+			if m == nil || !task.IsTask { // Not a checkbox or not a task according to cfg.
+				continue
+			}
+			loc := lintProblem{File: filename, Line: lineOf(data, task.Offset)}
+			for _, msg := range lintTask(cfg, task, m[1]) {
+				problems = append(problems, lintProblem{File: loc.File, Line: loc.Line, Message: msg})
+			}
+
+			if task.ID != "" {
+				if first, ok := ids[task.ID]; ok {
+					loc.Message = fmt.Sprintf("duplicate 🆔 %s, first at %s:%d", task.ID, first.File, first.Line)
+					problems = append(problems, loc)
+				} else {
+					ids[task.ID] = loc
+					graph[task.ID] = task.DependsOn
+				}
+			}
+			if len(task.DependsOn) > 0 {
+				dependents = append(dependents, loc)
+				dependsOn = append(dependsOn, task.DependsOn)
+			}
+		}
+	}
+
+	for i, loc := range dependents {
+		for _, id := range dependsOn[i] {
+			if _, ok := ids[id]; !ok {
+				loc.Message = fmt.Sprintf("⛔ unknown task ID %s", id)
+				problems = append(problems, loc)
+			}
+		}
+	}
+	for _, cycle := range findCycles(graph) {
+		loc := ids[cycle[0]]
+		loc.Message = "dependency cycle: " + strings.Join(append(cycle, cycle[0]), " ⛔ ")
+		problems = append(problems, loc)
+	}
+
+	slices.SortStableFunc(problems, func(a, b lintProblem) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+	return problems, nil
+}
+
+// lintTask returns problems found in a single task with given status symbol.
+func lintTask(cfg *tasksConfig, task Task, status string) []string {
+	var problems []string
+	if _, ok := cfg.Statuses[status]; !ok && !knownStatusSymbols[status] {
+		problems = append(problems, fmt.Sprintf("unknown status symbol %q", status))
+	}
+
+	seen := make(map[string]bool)
+	dates := make(map[string]time.Time)
+	for _, m := range reTaskDate.FindAllStringSubmatch(task.Line, -1) {
+		emoji, value := m[1], m[2]
+		if seen[emoji] {
+			problems = append(problems, fmt.Sprintf("duplicated %s", emoji))
+			continue
+		}
+		seen[emoji] = true
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("bad %s date %q", emoji, value))
+			continue
+		}
+		dates[emoji] = date
+	}
+
+	start, hasStart := dates["🛫"]
+	due, hasDue := dates["📅"]
+	if hasStart && hasDue && start.After(due) {
+		problems = append(problems, fmt.Sprintf("start date 🛫 %s is after due date 📅 %s",
+			start.Format(time.DateOnly), due.Format(time.DateOnly)))
+	}
+	if (status == "x" || status == "X") && !seen["✅"] {
+		problems = append(problems, "done task without ✅ date")
+	}
+	return problems
+}
+
+// findCycles returns dependency cycles in graph, each cycle is reported once.
+// Dependencies missing in graph are ignored.
+func findCycles(graph map[string][]string) [][]string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycles [][]string
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range graph[id] {
+			_, known := graph[dep]
+			switch {
+			case state[dep] == visiting:
+				cycles = append(cycles, slices.Clone(stack[slices.Index(stack, dep):]))
+			case state[dep] == 0 && known:
+				visit(dep)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+	}
+	for _, id := range slices.Sorted(maps.Keys(graph)) {
+		if state[id] == 0 {
+			visit(id)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/powerman/goldmark-obsidian/obsast"
+)
+
+func TestLintFiles(t *testing.T) {
+	files := map[string][]byte{
+		"a.md": []byte(`# Tasks
+
+- [ ] Good 🛫 2024-01-10 📅 2024-01-15
+- [ ] Impossible date 📅 2024-02-30
+- [ ] Two due dates 📅 2024-01-15 📅 2024-01-16
+- [ ] Starts after due 🛫 2024-01-16 📅 2024-01-15
+- [x] Done without date
+- [x] Done ✅ 2024-01-15
+- [?] Unknown status
+- Not a task 📅 bad
+- [ ] First 🆔 a ⛔ b
+  - [ ] Nested 🆔 b ⛔ a, c
+
+` + "```" + `
+- [ ] In code 📅 bad
+` + "```" + `
+`),
+		"b.md": []byte(`- [ ] Duplicate 🆔 a
+- [ ] Self 🆔 self ⛔ self
+`),
+	}
+	want := []lintProblem{
+		{File: "a.md", Line: 4, Message: `bad 📅 date "2024-02-30"`},
+		{File: "a.md", Line: 5, Message: "duplicated 📅"},
+		{File: "a.md", Line: 6, Message: "start date 🛫 2024-01-16 is after due date 📅 2024-01-15"},
+		{File: "a.md", Line: 7, Message: "done task without ✅ date"},
+		{File: "a.md", Line: 9, Message: `unknown status symbol "?"`},
+		{File: "a.md", Line: 11, Message: "dependency cycle: a ⛔ b ⛔ a"},
+		{File: "a.md", Line: 12, Message: "⛔ unknown task ID c"},
+		{File: "b.md", Line: 1, Message: "duplicate 🆔 a, first at a.md:11"},
+		{File: "b.md", Line: 2, Message: "dependency cycle: self ⛔ self"},
+	}
+
+	got, err := lintFiles(&tasksConfig{}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lintFiles() =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestLintFilesTasksConfig(t *testing.T) {
+	files := map[string][]byte{
+		"a.md": []byte(`- [ ] Task #task 📅 2024-02-30
+- [ ] Not a task 📅 2024-02-30
+- [x] Not a task, done without date
+- [?] Question 📅 2024-02-30
+- [!] Custom status #task
+`),
+	}
+	cfg := &tasksConfig{
+		GlobalFilter: "#task",
+		Statuses:     map[string]obsast.PlugTasksStatusType{"?": obsast.PlugTasksStatusTypeNonTask, "!": obsast.PlugTasksStatusTypeTODO},
+	}
+	want := []lintProblem{
+		{File: "a.md", Line: 1, Message: `bad 📅 date "2024-02-30"`},
+	}
+
+	got, err := lintFiles(cfg, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lintFiles() =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.md")
+	err := os.WriteFile(path, []byte("- [ ] Task 📅 2024-13-01\n- [ ] Good 📅 2024-12-01\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := runLint(&config{}, "text", &buf, []string{path})
+	if err != nil || n != 1 {
+		t.Fatalf("runLint() = %d, %v", n, err)
+	}
+	if want := path + ":1: bad 📅 date \"2024-13-01\"\n"; buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	n, err = runLint(&config{}, "json", &buf, []string{path})
+	if err != nil || n != 1 {
+		t.Fatalf("runLint() = %d, %v", n, err)
+	}
+	var got []lintProblem
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []lintProblem{{File: path, Line: 1, Message: `bad 📅 date "2024-13-01"`}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("json output = %v, want %v", got, want)
+	}
+
+	_, err = runLint(&config{}, "xml", &buf, []string{path})
+	if err == nil {
+		t.Error("runLint() with bad format: expected error")
+	}
+}
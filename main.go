@@ -5,90 +5,431 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
-
-	obsidian "github.com/powerman/goldmark-obsidian"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/renderer"
-	"github.com/yuin/goldmark/util"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Support -tz on systems without zoneinfo.
 )
 
-const emailSubject = "Actual tasks"
+const (
+	emailSubject  = "Actual tasks"
+	blockedHeader = "Blocked by unfinished tasks:\n\n"
+)
 
 func main() {
 	log.SetFlags(0)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(lintMain(os.Args[2:]))
+		case "done":
+			os.Exit(doneMain(os.Args[2:]))
+		case "snooze":
+			os.Exit(snoozeMain(os.Args[2:]))
+		case "reschedule-overdue":
+			os.Exit(rescheduleOverdueMain(os.Args[2:]))
+		case "serve":
+			os.Exit(serveMain(os.Args[2:]))
+		}
+	}
+
 	fromDay := flag.Int("from-day", 0, "Start day relative to today (-1 for yesterday, 0 for today)")
 	toDay := flag.Int("to-day", 0, "End day relative to today (1 for tomorrow)")
+	window := flag.String("window", "", "Days to include (overrides -from-day and -to-day): today, tomorrow, this-week, next-week,\n"+
+		"this-month, next-month, next N days, next N business days, until WEEKDAY, YYYY-MM-DD..YYYY-MM-DD")
+	weekStart := flag.String("week-start", "monday", "First day of the week for -window")
+	businessDays := flag.Bool("business-days", false, "Count -from-day, -to-day, tomorrow and yesterday in working days\n"+
+		"and include following non-working days into the window")
+	holidays := flag.String("holidays", "", "Load holidays for -business-days from this .ics or .yaml file (implies -business-days)")
+	expandRecurring := flag.Bool("expand-recurring", false, "Also output upcoming occurrences of recurring tasks within the window")
+	showBlocked := flag.Bool("show-blocked", false, "Output tasks blocked by unfinished tasks (⛔) in a separate section instead of hiding them")
+	workdaysOnly := flag.Bool("workdays-only", false, "Do nothing if today is not a working day")
+	date := flag.String("date", "", "Use this date (YYYY-MM-DD) as today")
+	tz := flag.String("tz", "", "Use this timezone (e.g. Europe/Kyiv) to detect today (default local)")
 	emailTo := flag.String("email", "", "Send output to this email address instead of stdout")
+	dryRun := flag.Bool("dry-run", false, "Print email to stdout instead of sending it")
+	emlOut := flag.String("eml-out", "", "Write email as .eml file into this directory instead of sending it")
+	outboxDir := flag.String("outbox", defaultOutboxDir(), "Queue failed emails in this directory to retry on next run (empty to disable)")
+	outboxMaxAge := flag.Duration("outbox-max-age", defaultOutboxMaxAge, "Drop queued emails older than this")
+	maxFileSize := flag.Int64("max-file-size", defaultMaxFileSize, "Skip Markdown files larger than this amount of bytes (0 for no limit)")
+	cacheFile := flag.String("cache", defaultTaskCacheFile(), "Cache tasks of unchanged files in this file to avoid parsing them again (empty to disable)")
+	var cfg config
+	fileFlags(flag.CommandLine, &cfg.Files)
+	tasksConfigFlags(flag.CommandLine, &cfg.Tasks)
+	watch := flag.Bool("watch", false, "Keep running and output tasks which enter the window or become overdue when files or today change")
+	watchDebounce := flag.Duration("watch-debounce", defaultWatchDebounce, "Wait for no more changes during this time before re-reading changed files")
 	flag.Parse()
-	if *fromDay > *toDay {
-		log.Fatalln("Error: from-day must be less than or equal to to-day")
+	cfg.Tasks.load(flag.Args())
+	now, err := parseNow(*date, *tz)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	cal := &Calendar{}
+	if *holidays != "" {
+		*businessDays = true
+		cal, err = LoadCalendar(*holidays)
+		if err != nil {
+			log.Fatalln("Error:", err)
+		}
+	}
+	if *dryRun && *emlOut != "" {
+		log.Fatalln("Error: -dry-run can't be used with -eml-out")
+	}
+	if *watch && (*date != "" || flag.NArg() == 0) {
+		log.Fatalln("Error: -watch requires PATH and can't be used with -date")
+	}
+	if *workdaysOnly && !*watch && !cal.IsWorkday(now) {
+		return
+	}
+	workdays := cal
+	if !*businessDays {
+		cal = nil
+	}
+	if *window != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "from-day" || f.Name == "to-day" {
+				log.Fatalln("Error: -window can't be used with -from-day or -to-day")
+			}
+		})
+	}
+	ws, err := parseWeekday(*weekStart)
+	if err != nil {
+		log.Fatalln("Error: bad -week-start:", err)
+	}
+	windowAt := func(now time.Time) (int, int, error) {
+		from, to, err := resolveWindow(now, *window, ws, *fromDay, *toDay, cal)
+		if err == nil && from > to {
+			err = errors.New("from-day must be less than or equal to to-day")
+		}
+		return from, to, err
+	}
+	from, to, err := windowAt(now)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+
+	opts := &filterOptions{
+		Now:             now,
+		FromDay:         from,
+		ToDay:           to,
+		ExpandRecurring: *expandRecurring,
+		ShowBlocked:     *showBlocked,
+		MaxFileSize:     *maxFileSize,
+		Config:          cfg,
+	}
+	if *cacheFile != "" && !*watch {
+		opts.Cache, err = LoadTaskCache(*cacheFile, &opts.Config.Tasks)
+		if err != nil {
+			log.Println("Warning: Failed to", err)
+		}
 	}
 
-	err := run(fromDay, toDay, emailTo, nil, os.Stdout, flag.Args())
+	var emailCfg *EmailConfig
+	if *emailTo != "" {
+		emailCfg = NewEmailConfigFromEnv()
+		emailCfg.DKIM, err = NewDKIMConfigFromEnv(emailCfg.From)
+		if err != nil {
+			log.Fatalln("Error:", err)
+		}
+		opts.Actions, err = NewActionsFromEnv()
+		if err != nil {
+			log.Fatalln("Error:", err)
+		}
+		switch {
+		case *dryRun:
+			emailCfg.SendMail = dryRunSendMail(os.Stdout)
+		case *emlOut != "":
+			emailCfg.SendMail = emlSendMail(*emlOut)
+		case *outboxDir != "":
+			emailCfg.Outbox = NewOutbox(*outboxDir, *outboxMaxAge)
+		}
+	}
+
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		loc := now.Location()
+		var email *Email
+		if *emailTo != "" {
+			email = NewEmail(emailCfg)
+		}
+		w := newWatcher(*opts, *emailTo, windowAt)
+		if *workdaysOnly {
+			w.workdays = workdays
+		}
+		err = runWatch(ctx, w, flag.Args(), *watchDebounce,
+			func() time.Time { return time.Now().In(loc) },
+			func(bufs map[string]*bytes.Buffer) error { return output(bufs, email, os.Stdout) },
+			func() (time.Time, error) {
+				if email == nil {
+					return time.Time{}, nil
+				}
+				err := email.Retry()
+				if err != nil {
+					return time.Time{}, err
+				}
+				return email.NextRetry()
+			})
+	} else {
+		err = run(opts, emailTo, emailCfg, os.Stdout, flag.Args())
+	}
 	if err != nil {
 		log.Fatalln("Failed to", err)
 	}
 }
 
-// run is testable part of main function.
-func run(fromDay, toDay *int, emailTo *string, emailCfg *EmailConfig, stdout io.Writer, paths []string) error {
-	files, err := readMarkdownFilesOrStdin(paths)
+// parseNow returns current time in timezone tz (local if empty) or start of date
+// (if not empty) in timezone tz.
+func parseNow(date, tz string) (time.Time, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("bad -tz: %w", err)
+		}
+	}
+	if date == "" {
+		return time.Now().In(loc), nil
+	}
+	now, err := time.ParseInLocation(time.DateOnly, date, loc)
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("bad -date: %w", err)
 	}
+	return now, nil
+}
+
+// config contains settings, given by flags and loaded from vaults, which select
+// Markdown files in PATHs and tasks in them. Zero value contains default settings.
+type config struct {
+	Files fileFilter
+	Tasks tasksConfig
+}
+
+// filterOptions holds configuration for filtering tasks.
+type filterOptions struct {
+	Config          config     // Files and tasks to filter.
+	Now             time.Time  // Today is the date of Now in Now's location.
+	FromDay         int        // Start day relative to today.
+	ToDay           int        // End day relative to today.
+	ExpandRecurring bool       // Also output upcoming occurrences of recurring tasks.
+	ShowBlocked     bool       // Output tasks blocked by not done tasks in a separate section.
+	Actions         *Actions   // If not nil, output action links below tasks.
+	Cache           *TaskCache // If not nil, used to avoid parsing unchanged files.
+	MaxFileSize     int64      // If > 0, larger files are skipped by filterMarkdownPaths.
+	// If not nil, called for each output task (line 0 for upcoming occurrences of recurring tasks).
+	OnMatch func(filename string, note noteSettings, task Task, line int)
+	// If not nil, filled with recipients (from frontmatter, see noteSettings) of files with output tasks.
+	Recipients map[string][]string
+}
 
-	tasks, err := filterMarkdownFiles(files, fromDay, toDay)
+// run is testable part of main function.
+func run(opts *filterOptions, emailTo *string, emailCfg *EmailConfig, stdout io.Writer, paths []string) error {
+	var tasks, blocked map[string][]byte
+	var err error
+	if *emailTo != "" && opts.Recipients == nil {
+		opts.Recipients = make(map[string][]string)
+	}
+	if len(paths) > 0 {
+		tasks, blocked, err = filterMarkdownPaths(opts, paths)
+	} else {
+		var files map[string][]byte
+		files, err = opts.Config.Files.readMarkdownFilesOrStdin(paths)
+		if err == nil {
+			tasks, blocked, err = filterMarkdownFiles(opts, files)
+		}
+	}
 	if err != nil {
 		return err
 	}
+	err = opts.Cache.Save()
+	if err != nil {
+		log.Println("Warning: Failed to", err)
+	}
 
+	var email *Email // Also retries queued emails if there are no tasks.
+	if *emailTo != "" {
+		email = NewEmail(emailCfg)
+	}
+	return output(routeOutput(tasks, blocked, opts.Recipients, *emailTo), email, stdout)
+}
+
+// formatOutput formats tasks and blocked tasks (in a separate section) of files.
+func formatOutput(tasks, blocked map[string][]byte) bytes.Buffer {
 	buf := formatTasks(tasks)
+	if len(blocked) > 0 {
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(blockedHeader)
+		blockedBuf := formatTasks(blocked)
+		buf.Write(blockedBuf.Bytes())
+	}
+	return buf
+}
 
-	if *emailTo == "" {
-		_, err = io.Copy(stdout, &buf)
-	} else if buf.Len() > 0 { // Don't send email if there are no tasks
-		err = NewEmail(emailCfg).Send(*emailTo, emailSubject, &buf)
+// routeOutput returns output (see formatOutput) for each address which gets tasks, see routeTasks.
+func routeOutput(tasks, blocked map[string][]byte, recipients map[string][]string, defaultTo string) map[string]*bytes.Buffer {
+	routedTasks := routeTasks(tasks, recipients, defaultTo)
+	routedBlocked := routeTasks(blocked, recipients, defaultTo)
+	bufs := make(map[string]*bytes.Buffer)
+	for _, routed := range []map[string]map[string][]byte{routedTasks, routedBlocked} {
+		for to := range routed {
+			buf := formatOutput(routedTasks[to], routedBlocked[to])
+			bufs[to] = &buf
+		}
 	}
-	return err
+	return bufs
 }
 
-// filterMarkdownFiles processes each file and returns a map of filenames to their filtered
-// task content.
-func filterMarkdownFiles(files map[string][]byte, fromDay *int, toDay *int) (map[string][]byte, error) {
-	tasks := make(map[string][]byte)
+// routeTasks groups files by email address: files with recipients are sent to
+// each of them and other files are sent to defaultTo.
+func routeTasks(files map[string][]byte, recipients map[string][]string, defaultTo string) map[string]map[string][]byte {
+	routed := make(map[string]map[string][]byte)
 	for filename, data := range files {
-		var buf bytes.Buffer
-		err := filterActualTasks(*fromDay, *toDay, data, &buf)
-		if err != nil {
-			return nil, fmt.Errorf("filter tasks: %w", err)
+		to := recipients[filename]
+		if len(to) == 0 {
+			to = []string{defaultTo}
+		}
+		for _, addr := range to {
+			if routed[addr] == nil {
+				routed[addr] = make(map[string][]byte)
+			}
+			routed[addr][filename] = data
+		}
+	}
+	return routed
+}
+
+// output writes buf for empty address to stdout and sends other bufs by email
+// to their addresses. Emails queued in outbox are retried first if email is not nil.
+func output(bufs map[string]*bytes.Buffer, email *Email, stdout io.Writer) error {
+	if email != nil {
+		if errRetry := email.Retry(); errRetry != nil {
+			log.Println("Warning: Failed to retry queued emails:", errRetry)
+		}
+	}
+	var errs []error
+	for _, to := range slices.Sorted(maps.Keys(bufs)) {
+		buf := bufs[to]
+		switch {
+		case buf.Len() == 0: // Don't send email if there are no tasks
+		case to == "":
+			_, err := io.Copy(stdout, buf)
+			errs = append(errs, err)
+		default:
+			errs = append(errs, email.Send(to, emailSubject, buf))
 		}
+	}
+	return errors.Join(errs...)
+}
+
+// filterMarkdownFiles processes each file and returns a map of filenames to their filtered
+// task content and a map of filenames to their blocked tasks (if opts.ShowBlocked).
+func filterMarkdownFiles(opts *filterOptions, files map[string][]byte) (tasks, blocked map[string][]byte, _ error) {
+	return filterFiles(opts, slices.Collect(maps.Keys(files)), func(filename string) ([]byte, error) {
+		return files[filename], nil
+	})
+}
+
+// filterMarkdownPaths is like filterMarkdownFiles for Markdown files from paths,
+// but files are read only when needed and files larger than opts.MaxFileSize are skipped.
+func filterMarkdownPaths(opts *filterOptions, paths []string) (tasks, blocked map[string][]byte, _ error) {
+	filenames, err := opts.Config.Files.listMarkdownFiles(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filterFiles(opts, filenames, func(filename string) ([]byte, error) {
+		return readMarkdownFile(filename, opts.MaxFileSize)
+	})
+}
+
+// filterFiles implements filterMarkdownFiles for files returned by read.
+func filterFiles(
+	opts *filterOptions, filenames []string, read func(filename string) ([]byte, error),
+) (tasks, blocked map[string][]byte, _ error) {
+	parsed, err := parseFiles(&opts.Config.Tasks, filenames, read, opts.Cache)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filter tasks: %w", err)
+	}
+	index := indexTasks(parsed)
+
+	tasks = make(map[string][]byte)
+	blocked = make(map[string][]byte)
+	for filename, file := range parsed {
+		var buf, blockedBuf bytes.Buffer
+		var blockedW io.Writer
+		if opts.ShowBlocked {
+			blockedW = &blockedBuf
+		}
+		writeActualTasks(opts, index, filename, file, &buf, blockedW)
 		if buf.Len() > 0 {
 			tasks[filename] = buf.Bytes()
 		}
+		if blockedBuf.Len() > 0 {
+			blocked[filename] = blockedBuf.Bytes()
+		}
+		if opts.Recipients != nil && buf.Len()+blockedBuf.Len() > 0 && len(file.Settings.Recipients) > 0 {
+			opts.Recipients[filename] = file.Settings.Recipients
+		}
 	}
-	return tasks, nil
+	return tasks, blocked, nil
 }
 
 // filterActualTasks filters the actual tasks from the markdown data.
-func filterActualTasks(dayFrom int, dayTo int, markdownData []byte, filteredTasks io.Writer) error {
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			obsidian.NewPlugTasks(),
-			obsidian.NewObsidian(),
-		),
-		goldmark.WithRendererOptions(renderer.WithNodeRenderers(
-			// Prio <500 needed to overwrite extension.GFM rendering to HTML.
-			util.Prioritized(NewActualTasksRenderer(dayFrom, dayTo), 0),
-		)),
-	)
-	err := md.Convert(markdownData, filteredTasks)
-	return err
+// Tasks blocked by tasks in index are written to blockedTasks or skipped if it is nil.
+// Filename is used only for action links and opts.OnMatch.
+func filterActualTasks(
+	opts *filterOptions, index map[string]TaskRef, filename string, markdownData []byte,
+	filteredTasks, blockedTasks io.Writer,
+) error {
+	file, err := parseFileTasks(newMarkdown(), &opts.Config.Tasks, filename, markdownData)
+	if err != nil {
+		return err
+	}
+	writeActualTasks(opts, index, filename, file, filteredTasks, blockedTasks)
+	return nil
+}
+
+// writeActualTasks writes the actual tasks of the file, see filterActualTasks.
+// Nothing is written if the note settings require to skip its tasks.
+func writeActualTasks(
+	opts *filterOptions, index map[string]TaskRef, filename string, file parsedFile,
+	filteredTasks, blockedTasks io.Writer,
+) {
+	if file.Settings.Skip {
+		return
+	}
+	r := opts.renderer(index, filename, file.Settings)
+	r.Blocked = blockedTasks
+	for _, t := range file.Tasks {
+		r.render(filteredTasks, t.Task, t.Line)
+	}
+}
+
+// renderer returns renderer of tasks of the file (with given note settings)
+// configured by opts. Blocked tasks are not rendered.
+func (opts *filterOptions) renderer(index map[string]TaskRef, filename string, note noteSettings) *FilteredTasksRenderer {
+	r := NewActualTasksRenderer(opts.Now, opts.FromDay, opts.ToDay)
+	r.ExpandRecurring = opts.ExpandRecurring
+	r.Tasks = index
+	if opts.Actions != nil {
+		r.Actions = func(task Task, line int) string { return opts.Actions.Links(filename, task, line) }
+	}
+	if opts.OnMatch != nil {
+		r.OnMatch = func(task Task, line int) { opts.OnMatch(filename, note, task, line) }
+	}
+	return r
 }
 
 // formatTasks takes filtered tasks and formats them with filenames into a single buffer.
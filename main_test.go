@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"net/smtp"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"text/template"
@@ -161,7 +163,7 @@ func TestRun(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var buf bytes.Buffer
-			err := filterActualTasks(tt.fromDate, tt.toDate, tt.input, &buf)
+			err := filterActualTasks(&filterOptions{Now: time.Now(), FromDay: tt.fromDate, ToDay: tt.toDate}, nil, "", tt.input, &buf, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -223,7 +225,7 @@ func TestFilterMarkdownFiles(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := filterMarkdownFiles(tt.files, &tt.fromDay, &tt.toDay)
+			got, _, err := filterMarkdownFiles(&filterOptions{Now: time.Now(), FromDay: tt.fromDay, ToDay: tt.toDay}, tt.files)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("filterMarkdownFiles() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -241,6 +243,44 @@ func TestFilterMarkdownFiles(t *testing.T) {
 	}
 }
 
+func TestFilterMarkdownPaths(t *testing.T) {
+	dir := t.TempDir()
+	task := "- [ ] Due today 📅 " + time.Now().Format(time.DateOnly) + "\n"
+	files := map[string]string{
+		"small.md":  task,
+		"large.md":  task + strings.Repeat("text\n", 100),
+		"notask.md": "# No tasks here\n",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		maxFileSize int64
+		want        []string
+	}{
+		{0, []string{"large.md", "small.md"}},
+		{100, []string{"small.md"}},
+	}
+	for _, tt := range tests {
+		got, _, err := filterMarkdownPaths(&filterOptions{Now: time.Now(), MaxFileSize: tt.maxFileSize}, []string{dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for filename := range got {
+			names = append(names, filepath.Base(filename))
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("filterMarkdownPaths(MaxFileSize=%d) = %v, want %v", tt.maxFileSize, names, tt.want)
+		}
+	}
+}
+
 func TestFormatTasks(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -336,7 +376,7 @@ func TestNoEmailWhenEmpty(t *testing.T) {
 
 	// Run the test
 	var stdout bytes.Buffer
-	err = run(&fromDay, &toDay, &emailTo, emailCfg, &stdout, []string{tempFile})
+	err = run(&filterOptions{Now: time.Now(), FromDay: fromDay, ToDay: toDay}, &emailTo, emailCfg, &stdout, []string{tempFile})
 	if err != nil {
 		t.Errorf("run() unexpected error = %v", err)
 	}
@@ -380,7 +420,7 @@ func TestEmailWithTasks(t *testing.T) {
 
 	// Run the test
 	var stdout bytes.Buffer
-	err = run(&fromDay, &toDay, &emailTo, emailCfg, &stdout, []string{tempFile})
+	err = run(&filterOptions{Now: time.Now(), FromDay: fromDay, ToDay: toDay}, &emailTo, emailCfg, &stdout, []string{tempFile})
 	if err != nil {
 		t.Errorf("run() unexpected error = %v", err)
 	}
@@ -390,6 +430,54 @@ func TestEmailWithTasks(t *testing.T) {
 	}
 }
 
+func TestRunDebugEmail(t *testing.T) {
+	tempFile := filepath.Join(t.TempDir(), "tasks.md")
+	err := os.WriteFile(tempFile, []byte("- [ ] Test task 📅 "+time.Now().Format(time.DateOnly)+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emailTo := "to@example.com"
+	newEmailCfg := func(sendMail func(string, smtp.Auth, string, []string, []byte) error) *EmailConfig {
+		return &EmailConfig{Host: "localhost", Port: 25, From: "from@example.com", SendMail: sendMail}
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run(&filterOptions{Now: time.Now()}, &emailTo, newEmailCfg(dryRunSendMail(&stdout)), &stdout, []string{tempFile})
+		if err != nil {
+			t.Fatalf("run() unexpected error = %v", err)
+		}
+		for _, want := range []string{"RCPT TO:<to@example.com>", "Subject: " + emailSubject, "- [ ] Test task"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("run() output should contain %q\nOutput:\n%s", want, stdout.String())
+			}
+		}
+	})
+
+	t.Run("EMLOut", func(t *testing.T) {
+		dir := t.TempDir()
+		var stdout bytes.Buffer
+		err := run(&filterOptions{Now: time.Now()}, &emailTo, newEmailCfg(emlSendMail(dir)), &stdout, []string{tempFile})
+		if err != nil {
+			t.Fatalf("run() unexpected error = %v", err)
+		}
+		if stdout.Len() > 0 {
+			t.Errorf("run() should not write to stdout, got: %s", stdout.String())
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("eml files = %v, %v, want 1 file", files, err)
+		}
+		msg, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(msg), "To: to@example.com\r\n") || !strings.Contains(string(msg), "- [ ] Test task") {
+			t.Errorf("eml file =\n%s", msg)
+		}
+	})
+}
+
 func TestRun_Integration(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -432,7 +520,7 @@ func TestRun_Integration(t *testing.T) {
 					}
 				}()
 			}
-			err := run(&tt.fromDay, &tt.toDay, &tt.emailTo, nil, &stdout, tt.paths)
+			err := run(&filterOptions{Now: time.Now(), FromDay: tt.fromDay, ToDay: tt.toDay}, &tt.emailTo, nil, &stdout, tt.paths)
 			if !tt.wantPanic {
 				if (err != nil) != tt.wantErr {
 					t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
@@ -441,3 +529,210 @@ func TestRun_Integration(t *testing.T) {
 		})
 	}
 }
+
+func TestParseNow(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    string
+		tz      string
+		want    string
+		wantErr bool
+	}{
+		{name: "Date", date: "2024-01-15", want: "2024-01-15"},
+		{name: "Date with timezone", date: "2024-01-15", tz: "Europe/Kyiv", want: "2024-01-15 EET"},
+		{name: "Bad date", date: "15.01.2024", wantErr: true},
+		{name: "Bad timezone", tz: "Nowhere/Never", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNow(tt.date, tt.tz)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseNow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			format := time.DateOnly
+			if tt.tz != "" {
+				format += " MST"
+			}
+			if got.Format(format) != tt.want {
+				t.Errorf("parseNow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got, err := parseNow("", "Pacific/Kiritimati")
+	if err != nil || got.Location().String() != "Pacific/Kiritimati" {
+		t.Errorf("parseNow() = %v, %v, want now in Pacific/Kiritimati", got, err)
+	}
+}
+
+func TestFilterActualTasksAsOf(t *testing.T) {
+	input := []byte("- [ ] Monday 📅 2024-01-15\n- [ ] Tuesday 📅 2024-01-16\n")
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"Monday", time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), "- [ ] Monday 📅 2024-01-15\n"},
+		{"Late Monday in UTC is Tuesday in Kyiv", time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC).In(kyiv), "- [ ] Tuesday 📅 2024-01-16\n"},
+		{"Tuesday midnight in Kyiv", time.Date(2024, 1, 16, 0, 0, 0, 0, kyiv), "- [ ] Tuesday 📅 2024-01-16\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := filterActualTasks(&filterOptions{Now: tt.now}, nil, "", input, &buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("filterActualTasks() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandRecurring(t *testing.T) {
+	input := []byte(`- [ ] Weekly 🔁 every week on Monday 📅 2024-01-15
+- [ ] Daily started 🔁 every day ⏳ 2024-01-16 🛫 2024-01-16
+- [ ] Monthly 🔁 every month 📅 2024-01-10
+- [ ] When done 🔁 every 3 days when done 📅 2024-01-12
+- [x] Done weekly 🔁 every week 📅 2024-01-15
+- [ ] Bad rule 🔁 every blue moon 📅 2024-01-15
+- [ ] End of month 🔁 every month 📅 2023-12-31
+`)
+	monday := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		opts filterOptions
+		want string
+	}{
+		{
+			name: "Disabled",
+			opts: filterOptions{Now: monday, FromDay: 0, ToDay: 13},
+			want: "- [ ] Weekly 🔁 every week on Monday 📅 2024-01-15\n" +
+				"- [ ] Bad rule 🔁 every blue moon 📅 2024-01-15\n",
+		},
+		{
+			name: "Next 14 days",
+			opts: filterOptions{Now: monday, FromDay: 0, ToDay: 13, ExpandRecurring: true},
+			want: "- [ ] Weekly 🔁 every week on Monday 📅 2024-01-15\n" +
+				"- [ ] Weekly 🔁 every week on Monday 📅 2024-01-15 (upcoming 2024-01-22)\n" +
+				"- [ ] When done 🔁 every 3 days when done 📅 2024-01-12 (upcoming 2024-01-18)\n" +
+				"- [ ] Bad rule 🔁 every blue moon 📅 2024-01-15\n",
+		},
+		{
+			name: "Tomorrow",
+			opts: filterOptions{Now: monday, FromDay: 1, ToDay: 1, ExpandRecurring: true},
+			want: "",
+		},
+		{
+			name: "Start date in future",
+			opts: filterOptions{Now: monday.AddDate(0, 0, 2), FromDay: 0, ToDay: 1, ExpandRecurring: true},
+			want: "- [ ] Daily started 🔁 every day ⏳ 2024-01-16 🛫 2024-01-16 (upcoming 2024-01-17)\n",
+		},
+		{
+			name: "Day of month after shorter month",
+			opts: filterOptions{Now: time.Date(2024, 3, 31, 9, 0, 0, 0, time.Local), ExpandRecurring: true},
+			want: "- [ ] Daily started 🔁 every day ⏳ 2024-01-16 🛫 2024-01-16 (upcoming 2024-03-31)\n" +
+				"- [ ] End of month 🔁 every month 📅 2023-12-31 (upcoming 2024-03-31)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := filterActualTasks(&tt.opts, nil, "", input, &buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("filterActualTasks() =\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandRecurringOldTask(t *testing.T) {
+	input := []byte("- [ ] Daily since 2020 🔁 every day 📅 2020-01-01\n")
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	var buf bytes.Buffer
+	err := filterActualTasks(&filterOptions{Now: now, FromDay: 0, ToDay: 1, ExpandRecurring: true}, nil, "", input, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [ ] Daily since 2020 🔁 every day 📅 2020-01-01 (upcoming 2024-01-15)\n" +
+		"- [ ] Daily since 2020 🔁 every day 📅 2020-01-01 (upcoming 2024-01-16)\n"
+	if buf.String() != want {
+		t.Errorf("filterActualTasks() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestBlockedTasks(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	err := os.WriteFile(a, []byte(`- [ ] Write draft 🆔 draft 📅 2024-01-15
+- [ ] Publish ⛔ draft, proofread 📅 2024-01-15
+- [ ] Announce ⛔ review 📅 2024-01-15
+- [ ] Unknown dependency ⛔ nope 📅 2024-01-15
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(b, []byte(`- [x] Review 🆔 review
+- [/] Proofread 🆔 proofread
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local)
+	actual := a + ":\n" +
+		"- [ ] Write draft 🆔 draft 📅 2024-01-15\n" +
+		"- [ ] Announce ⛔ review 📅 2024-01-15\n" +
+		"- [ ] Unknown dependency ⛔ nope 📅 2024-01-15\n"
+
+	tests := []struct {
+		name        string
+		showBlocked bool
+		want        string
+	}{
+		{
+			name: "Hidden",
+			want: actual,
+		},
+		{
+			name:        "Shown",
+			showBlocked: true,
+			want: actual + "\n" + blockedHeader + a + ":\n" +
+				"- [ ] Publish ⛔ draft, proofread 📅 2024-01-15\n" +
+				"    ⛔ [ ] Write draft 🆔 draft 📅 2024-01-15 (" + a + ")\n" +
+				"    ⛔ [/] Proofread 🆔 proofread (" + b + ")\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			emailTo := ""
+			opts := &filterOptions{Now: now, ShowBlocked: tt.showBlocked}
+			err := run(opts, &emailTo, nil, &stdout, []string{a, b})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("run() =\n%s\nwant:\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOutboxMaxAge = 3 * 24 * time.Hour
+	outboxRetryMin      = 5 * time.Minute
+	outboxRetryMax      = 6 * time.Hour
+)
+
+// defaultOutboxDir returns outbox directory inside user's cache dir or empty string
+// if there is no cache dir.
+func defaultOutboxDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "md-tasks-notify", "outbox")
+}
+
+// isPermanentSMTPError reports whether err is a permanent (5xx) SMTP failure.
+// All other errors (4xx, network errors, timeouts) are considered transient.
+func isPermanentSMTPError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600
+}
+
+// outboxMessage is a queued email stored in the outbox directory as JSON.
+type outboxMessage struct {
+	From        string    `json:"from"`
+	To          []string  `json:"to"`
+	Msg         []byte    `json:"msg"`
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+}
+
+// Outbox is a persistent queue of emails which failed to send because of a transient error.
+type Outbox struct {
+	Dir    string
+	MaxAge time.Duration    // Messages older than this are dropped. Zero means no limit.
+	Now    func() time.Time // For testing.
+}
+
+// NewOutbox creates a new Outbox stored in dir.
+func NewOutbox(dir string, maxAge time.Duration) *Outbox {
+	return &Outbox{
+		Dir:    dir,
+		MaxAge: maxAge,
+		Now:    time.Now,
+	}
+}
+
+// Put stores message which failed to send with sendErr for a later retry.
+func (o *Outbox) Put(from string, to []string, msg []byte, sendErr error) error {
+	now := o.Now()
+	return o.save("", &outboxMessage{
+		From:        from,
+		To:          to,
+		Msg:         msg,
+		Created:     now,
+		Attempts:    1,
+		NextAttempt: now.Add(outboxBackoff(1)),
+		LastError:   sendErr.Error(),
+	})
+}
+
+// Flush tries to send all queued messages which are due for retry.
+// Sent messages, messages failed with permanent error and messages older than MaxAge
+// are removed from the outbox. Broken files are renamed to *.json.bad to not retry them.
+func (o *Outbox) Flush(send func(from string, to []string, msg []byte) error) error {
+	paths, err := filepath.Glob(filepath.Join(o.Dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	for _, path := range paths {
+		m, err := o.load(path)
+		if err != nil {
+			log.Printf("Warning: Skipping queued email: %v", err)
+			if errRename := os.Rename(path, path+".bad"); errRename != nil {
+				log.Printf("Warning: Failed to rename broken queued email: %v", errRename)
+			}
+			continue
+		}
+		now := o.Now()
+		switch {
+		case o.MaxAge > 0 && now.Sub(m.Created) > o.MaxAge:
+			log.Printf("Warning: Dropping email to %q queued at %s after %d attempts: %s",
+				m.To, m.Created.Format(time.DateTime), m.Attempts, m.LastError)
+		case now.Before(m.NextAttempt):
+			continue
+		default:
+			err = send(m.From, m.To, m.Msg)
+			switch {
+			case err == nil:
+			case isPermanentSMTPError(err):
+				log.Printf("Warning: Dropping email to %q queued at %s: %s",
+					m.To, m.Created.Format(time.DateTime), err)
+			default:
+				m.Attempts++
+				m.NextAttempt = now.Add(outboxBackoff(m.Attempts))
+				m.LastError = err.Error()
+				err = o.save(path, m)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("outbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// NextAttempt returns the earliest time of the next attempt to send a queued message
+// or zero time if the outbox is empty.
+func (o *Outbox) NextAttempt() (time.Time, error) {
+	paths, err := filepath.Glob(filepath.Join(o.Dir, "*.json"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("outbox: %w", err)
+	}
+	var next time.Time
+	for _, path := range paths {
+		m, err := o.load(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if next.IsZero() || m.NextAttempt.Before(next) {
+			next = m.NextAttempt
+		}
+	}
+	return next, nil
+}
+
+func (o *Outbox) load(path string) (*outboxMessage, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is from our own outbox dir.
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	var m outboxMessage
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// save writes m to path atomically, or to a new file if path is empty.
+func (o *Outbox) save(path string, m *outboxMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	err = os.MkdirAll(o.Dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	f, err := os.CreateTemp(o.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		if path == "" {
+			// Name starts with creation time to retry messages in order.
+			path = filepath.Join(o.Dir, fmt.Sprintf("%020d-%s.json",
+				m.Created.UnixNano(), strings.TrimPrefix(filepath.Base(f.Name()), ".tmp-")))
+		}
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("outbox: %w", err)
+	}
+	return nil
+}
+
+// outboxBackoff returns exponential delay before next attempt.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxRetryMin
+	for range attempts - 1 {
+		d *= 2
+		if d >= outboxRetryMax {
+			return outboxRetryMax
+		}
+	}
+	return d
+}
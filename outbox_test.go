@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powerman/check"
+)
+
+func TestIsPermanentSMTPError(tt *testing.T) {
+	t := check.T(tt)
+
+	t.False(isPermanentSMTPError(ErrMock))
+	t.False(isPermanentSMTPError(&textproto.Error{Code: 421, Msg: "try again later"}))
+	t.True(isPermanentSMTPError(&textproto.Error{Code: 550, Msg: "no such user"}))
+	t.True(isPermanentSMTPError(fmt.Errorf("wrapped: %w", &textproto.Error{Code: 554})))
+}
+
+func TestOutboxBackoff(tt *testing.T) {
+	t := check.T(tt)
+
+	t.Equal(outboxBackoff(1), outboxRetryMin)
+	t.Equal(outboxBackoff(2), 2*outboxRetryMin)
+	t.Equal(outboxBackoff(3), 4*outboxRetryMin)
+	t.Equal(outboxBackoff(100), outboxRetryMax)
+}
+
+func TestOutbox(tt *testing.T) {
+	t := check.T(tt)
+
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox"), 24*time.Hour)
+	outbox.Now = func() time.Time { return now }
+
+	queued := func() int {
+		t.Helper()
+		paths, err := filepath.Glob(filepath.Join(outbox.Dir, "*"))
+		t.Nil(err)
+		return len(paths)
+	}
+	var sent []string
+	sendErr := error(nil)
+	send := func(_ string, to []string, _ []byte) error {
+		if sendErr == nil {
+			sent = append(sent, to...)
+		}
+		return sendErr
+	}
+
+	t.Nil(outbox.Flush(send), "flush of missing dir")
+	next, err := outbox.NextAttempt()
+	t.Nil(err)
+	t.Zero(next, "empty")
+	t.Nil(outbox.Put("from", []string{"a@example.com"}, []byte("msg a"), ErrMock))
+	now = now.Add(time.Second)
+	t.Nil(outbox.Put("from", []string{"b@example.com"}, []byte("msg b"), ErrMock))
+	t.Equal(queued(), 2)
+	next, err = outbox.NextAttempt()
+	t.Nil(err)
+	t.Equal(next, now.Add(-time.Second+outboxRetryMin), "earliest")
+
+	t.Nil(outbox.Flush(send))
+	t.Len(sent, 0, "not due yet")
+
+	now = now.Add(outboxRetryMin)
+	sendErr = &textproto.Error{Code: 451, Msg: "try again later"}
+	t.Nil(outbox.Flush(send))
+	t.Equal(queued(), 2, "transient error")
+
+	now = now.Add(outboxRetryMin)
+	sendErr = nil
+	t.Nil(outbox.Flush(send))
+	t.Len(sent, 0, "backoff increased")
+
+	now = now.Add(outboxRetryMin)
+	sendErr = nil
+	t.Nil(outbox.Flush(send))
+	t.DeepEqual(sent, []string{"a@example.com", "b@example.com"})
+	t.Equal(queued(), 0)
+
+	t.Nil(outbox.Put("from", []string{"c@example.com"}, []byte("msg c"), ErrMock))
+	now = now.Add(outboxRetryMin)
+	sendErr = &textproto.Error{Code: 550, Msg: "no such user"}
+	t.Nil(outbox.Flush(send))
+	t.Equal(queued(), 0, "permanent error")
+
+	t.Nil(outbox.Put("from", []string{"d@example.com"}, []byte("msg d"), ErrMock))
+	now = now.Add(outbox.MaxAge + time.Second)
+	sendErr = nil
+	sent = nil
+	t.Nil(outbox.Flush(send))
+	t.Len(sent, 0)
+	t.Equal(queued(), 0, "too old")
+}
+
+func TestOutboxBroken(tt *testing.T) {
+	t := check.T(tt)
+
+	now := time.Now()
+	outbox := NewOutbox(t.TempDir(), 0)
+	outbox.Now = func() time.Time { return now }
+	broken := filepath.Join(outbox.Dir, "00000000000000000000-broken.json")
+	t.Nil(os.WriteFile(broken, []byte(`{"from":`), 0o600))
+	t.Nil(outbox.Put("from", []string{"a@example.com"}, []byte("msg a"), ErrMock))
+
+	var sent []string
+	send := func(_ string, to []string, _ []byte) error {
+		sent = append(sent, to...)
+		return nil
+	}
+	now = now.Add(outboxRetryMin)
+	t.Nil(outbox.Flush(send))
+	t.DeepEqual(sent, []string{"a@example.com"})
+	paths, err := filepath.Glob(filepath.Join(outbox.Dir, "*"))
+	t.Nil(err)
+	t.DeepEqual(paths, []string{broken + ".bad"})
+
+	t.Nil(outbox.Flush(send))
+	t.Len(sent, 1)
+}
+
+func TestEmailOutbox(tt *testing.T) {
+	t := check.T(tt)
+
+	now := time.Now()
+	outbox := NewOutbox(t.TempDir(), 0)
+	outbox.Now = func() time.Time { return now }
+	sendErr := error(ErrMock)
+	var sent []string
+	email := NewEmail(&EmailConfig{
+		Host:   "localhost",
+		Port:   25,
+		From:   "from@example.com",
+		Outbox: outbox,
+		SendMail: func(_ string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+			if sendErr == nil {
+				sent = append(sent, string(msg))
+			}
+			return sendErr
+		},
+	})
+
+	err := email.Send("to@example.com", "Test Subject", strings.NewReader("Hello, World!"))
+	t.Err(err, ErrMock)
+	t.Match(err, "queued for retry")
+
+	sendErr = &textproto.Error{Code: 550, Msg: "no such user"}
+	err = email.Send("to@example.com", "Test Subject", strings.NewReader("Bounce"))
+	t.True(errors.Is(err, sendErr))
+	t.NotMatch(err, "queued for retry")
+
+	now = now.Add(outboxRetryMin)
+	sendErr = nil
+	t.Nil(email.Retry())
+	t.Len(sent, 1)
+	t.HasSuffix(sent[0], "Hello, World!")
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+)
+
+// Increment on changes in Task or in parsing to invalidate existing caches.
+const taskCacheVersion = 6
+
+// defaultTaskCacheFile returns path to task cache inside user's cache dir or empty string
+// if there is no cache dir.
+func defaultTaskCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "md-tasks-notify", "tasks.json")
+}
+
+// parsedTask is a parsed task with its 1-based line number.
+type parsedTask struct {
+	Task Task
+	Line int
+}
+
+// parsedFile contains parsed tasks of a file and settings from its frontmatter.
+type parsedFile struct {
+	Tasks    []parsedTask
+	Settings noteSettings
+}
+
+// parseFileTasks returns all tasks in Markdown source of the file with their line numbers.
+// Settings from frontmatter are applied to tasks (see noteSettings) and then tasks
+// without dates may get scheduled date from filename, see tasksConfig.setFilenameDate.
+func parseFileTasks(md goldmark.Markdown, cfg *tasksConfig, filename string, source []byte) (parsedFile, error) {
+	file, err := parseNoteTasks(md, cfg, filename, source)
+	if err != nil {
+		return parsedFile{}, err
+	}
+	file.Tasks = cfg.setFilenameDate(filename, file.Tasks)
+	return file, nil
+}
+
+// parseNoteTasks returns all tasks in Markdown source of the file with their line
+// numbers and applied settings from frontmatter.
+func parseNoteTasks(md goldmark.Markdown, cfg *tasksConfig, filename string, source []byte) (parsedFile, error) {
+	tasks, settings, err := parseTasks(md, cfg, source)
+	if err != nil {
+		return parsedFile{}, err
+	}
+	if settings.err != nil {
+		log.Printf("Warning: Ignoring %s settings in %q: %v", noteSettingsKey, filename, settings.err)
+	}
+	parsed := make([]parsedTask, 0, len(tasks))
+	line, pos := 1, 0
+	for _, task := range tasks { // Tasks are in source order.
+		line += bytes.Count(source[pos:task.Offset], []byte("\n"))
+		pos = task.Offset
+		parsed = append(parsed, parsedTask{Task: task, Line: line})
+	}
+	settings.apply(parsed)
+	return parsedFile{Tasks: parsed, Settings: settings}, nil
+}
+
+// taskMarkers are byte sequences at least one of which is contained in every
+// task which may be output or may block output tasks: due and scheduled dates
+// and task ID, in emoji or Dataview format, or default due date in frontmatter.
+var taskMarkers = [][]byte{
+	[]byte("📅"), []byte("⏳"), []byte("🆔"),
+	[]byte("due::"), []byte("scheduled::"), []byte("id::"),
+	[]byte("default-due:"),
+}
+
+// mayHaveTasks is a fast check which reports false if source can't contain
+// tasks which may be output or may block output tasks, so it needs no parsing.
+func mayHaveTasks(source []byte) bool {
+	return bytes.IndexByte(source, '[') >= 0 &&
+		slices.ContainsFunc(taskMarkers, func(marker []byte) bool { return bytes.Contains(source, marker) })
+}
+
+// parseFiles returns tasks of files which may be output or may block output
+// tasks (see mayHaveTasks). Files are read (using read) and parsed in parallel,
+// one Markdown parser per worker, so only a few files are kept in memory at once.
+// Files too large to read (errFileTooLarge) are skipped with a warning.
+// Files unchanged since they were cached are not read at all if cache is not nil.
+// Cache must be loaded with the same cfg.
+func parseFiles(
+	cfg *tasksConfig, filenames []string, read func(filename string) ([]byte, error), cache *TaskCache,
+) (map[string]parsedFile, error) {
+	queue := make(chan string)
+	result := make(map[string]parsedFile, len(filenames))
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range min(runtime.GOMAXPROCS(0), len(filenames)) {
+		wg.Go(func() {
+			md := newMarkdown()
+			for filename := range queue {
+				file, err := parseFile(md, cfg, filename, read, cache)
+				if errors.Is(err, errFileTooLarge) {
+					log.Println("Warning: Skipping", err)
+					continue
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("parse %q: %w", filename, err)
+				}
+				result[filename] = file
+				mu.Unlock()
+			}
+		})
+	}
+	for _, filename := range filenames {
+		queue <- filename
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// parseMarkdownPaths is parseFiles for Markdown files from paths selected by cfg,
+// files larger than maxSize bytes (if maxSize > 0) are skipped.
+func parseMarkdownPaths(cfg *config, paths []string, maxSize int64) (map[string]parsedFile, error) {
+	filenames, err := cfg.Files.listMarkdownFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	return parseFiles(&cfg.Tasks, filenames, func(filename string) ([]byte, error) {
+		return readMarkdownFile(filename, maxSize)
+	}, nil)
+}
+
+// parseFile returns cached tasks of the file or reads and parses it.
+func parseFile(
+	md goldmark.Markdown, cfg *tasksConfig, filename string, read func(filename string) ([]byte, error), cache *TaskCache,
+) (parsedFile, error) {
+	file, info, ok := cache.get(filename)
+	if !ok {
+		data, err := read(filename)
+		if err != nil {
+			return parsedFile{}, err
+		}
+		// Files with date in name are parsed even outside of FilenameDateFolders
+		// because cached tasks must not depend on PATHs used by current run.
+		if mayHaveTasks(data) || (cfg.FilenameDate && !cfg.dateInFilename(filename).IsZero()) {
+			file, err = parseNoteTasks(md, cfg, filename, data)
+			if err != nil {
+				return parsedFile{}, err
+			}
+		}
+		cache.put(filename, info, file)
+	}
+	file.Tasks = cfg.setFilenameDate(filename, file.Tasks)
+	return file, nil
+}
+
+// cachedFile contains tasks extracted from a file with given size and modification time.
+type cachedFile struct {
+	Size     int64        `json:"size"`
+	ModTime  time.Time    `json:"mod_time"`
+	Tasks    []parsedTask `json:"tasks"`
+	Settings noteSettings `json:"settings"`
+}
+
+// taskCacheData is a content of task cache file.
+type taskCacheData struct {
+	Version     int                   `json:"version"`
+	TasksConfig tasksConfig           `json:"tasks_config"` // Cached tasks depend on it.
+	Files       map[string]cachedFile `json:"files"`
+}
+
+// TaskCache is an on-disk cache of tasks extracted from Markdown files.
+// Cached tasks of a file are used while its path, size and modification time
+// are not changed. Nil *TaskCache is a valid cache which caches nothing.
+// It is safe for concurrent use.
+type TaskCache struct {
+	File    string      // Path to cache file.
+	config  tasksConfig // Cached tasks were parsed with it.
+	mu      sync.Mutex
+	files   map[string]cachedFile
+	used    map[string]bool // Files requested since load.
+	changed bool
+}
+
+// LoadTaskCache returns cache stored in file for tasks parsed with cfg.
+// Missing, broken or outdated (including changed cfg) cache file results in empty cache.
+func LoadTaskCache(file string, cfg *tasksConfig) (*TaskCache, error) {
+	c := &TaskCache{
+		File:   file,
+		config: *cfg,
+		files:  make(map[string]cachedFile),
+		used:   make(map[string]bool),
+	}
+	data, err := os.ReadFile(file) //nolint:gosec // Path is provided by user.
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load task cache: %w", err)
+	}
+	var cached taskCacheData
+	if json.Unmarshal(data, &cached) == nil && cached.Version == taskCacheVersion &&
+		cached.TasksConfig.sameSettings(c.config) && cached.Files != nil {
+		c.files = cached.Files
+	}
+	return c, nil
+}
+
+// get returns cached tasks of the file and current information about the
+// file which must be given to put if tasks are not cached.
+func (c *TaskCache) get(filename string) (_ parsedFile, _ fs.FileInfo, ok bool) {
+	if c == nil || filename == "" {
+		return parsedFile{}, nil, false
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return parsedFile{}, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[filename] = true
+	cached, ok := c.files[filename]
+	if !ok || cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) {
+		return parsedFile{}, info, false
+	}
+	return parsedFile{Tasks: cached.Tasks, Settings: cached.Settings}, info, true
+}
+
+// put stores tasks of the file which was read after get returned info.
+func (c *TaskCache) put(filename string, info fs.FileInfo, file parsedFile) {
+	if c == nil || info == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[filename] = cachedFile{Size: info.Size(), ModTime: info.ModTime(), Tasks: file.Tasks, Settings: file.Settings}
+	c.changed = true
+}
+
+// Save writes cache to c.File if it was changed. Files which were not
+// requested since load and no longer exist are removed from cache.
+func (c *TaskCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filename := range c.files {
+		if c.used[filename] {
+			continue
+		}
+		if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+			delete(c.files, filename)
+			c.changed = true
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+	data, err := json.Marshal(taskCacheData{Version: taskCacheVersion, TasksConfig: c.config, Files: c.files})
+	if err != nil {
+		return fmt.Errorf("save task cache: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(c.File), 0o700)
+	if err != nil {
+		return fmt.Errorf("save task cache: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.File), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("save task cache: %w", err)
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Will fail after successful rename.
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.File)
+	}
+	if err != nil {
+		return fmt.Errorf("save task cache: %w", err)
+	}
+	c.changed = false
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseFileTasks(t *testing.T) {
+	source := []byte("# Title\n\n- [ ] First\n- Not a task\n  - [x] Nested\n\n```\n- [ ] Code\n```\n\n- [ ] Last\n")
+	file, err := parseFileTasks(newMarkdown(), &tasksConfig{}, "", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, task := range file.Tasks {
+		got[task.Task.Line] = task.Line
+	}
+	want := map[string]int{"[ ] First": 3, "[x] Nested": 5, "[ ] Last": 11}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFileTasks() lines = %v, want %v", got, want)
+	}
+}
+
+func TestMayHaveTasks(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"", false},
+		{"# Notes\n\nSome text 📅 2024-01-15\n", false},
+		{"- [ ] Task without dates\n", false},
+		{"- [x] Done 🛫 2024-01-15\n", false},
+		{"- [ ] Due 📅 2024-01-15\n", true},
+		{"* [ ] Scheduled ⏳ 2024-01-15\n", true},
+		{"1. [ ] Blocker 🆔 a\n", true},
+	}
+	for _, tt := range tests {
+		if got := mayHaveTasks([]byte(tt.source)); got != tt.want {
+			t.Errorf("mayHaveTasks(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestTaskCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache", "tasks.json")
+	file := filepath.Join(dir, "a.md")
+	data := []byte("- [ ] Task 0 📅 2024-01-15\n")
+	err := os.WriteFile(file, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(t *testing.T, data string) string {
+		t.Helper()
+		cfg := &tasksConfig{}
+		cache, err := LoadTaskCache(cacheFile, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseFiles(cfg, []string{file}, func(string) ([]byte, error) { return []byte(data), nil }, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cache.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed[file].Tasks) != 1 {
+			t.Fatalf("parseFiles() = %v, want 1 task", parsed)
+		}
+		return parsed[file].Tasks[0].Task.Line
+	}
+
+	// Data differs from file content to detect reading.
+	if got := parse(t, "- [ ] Task 1 📅 2024-01-15\n"); got != "[ ] Task 1 📅 2024-01-15" {
+		t.Errorf("not cached: got %q", got)
+	}
+	if got := parse(t, "- [ ] Task 2 📅 2024-01-15\n"); got != "[ ] Task 1 📅 2024-01-15" {
+		t.Errorf("cached: got %q", got)
+	}
+	err = os.Chtimes(file, time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parse(t, "- [ ] Task 3 📅 2024-01-15\n"); got != "[ ] Task 3 📅 2024-01-15" {
+		t.Errorf("mtime changed: got %q", got)
+	}
+
+	err = os.WriteFile(cacheFile, []byte("{broken"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parse(t, string(data)); got != "[ ] Task 0 📅 2024-01-15" {
+		t.Errorf("broken cache: got %q", got)
+	}
+}
+
+func TestTaskCacheFilenameDate(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache", "tasks.json")
+	file := filepath.Join(dir, "Daily", "2024-01-15.md")
+	err := os.Mkdir(filepath.Dir(file), 0o700)
+	if err == nil {
+		err = os.WriteFile(file, []byte("- [ ] Call client\n"), 0o600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(t *testing.T, root string) time.Time {
+		t.Helper()
+		cfg := &tasksConfig{FilenameDate: true, FilenameDateFolders: []string{"Daily"}, roots: []string{root}}
+		cache, err := LoadTaskCache(cacheFile, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseFiles(cfg, []string{file}, os.ReadFile, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cache.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed[file].Tasks) != 1 {
+			t.Fatalf("parseFiles() = %v, want 1 task", parsed)
+		}
+		return parsed[file].Tasks[0].Task.Scheduled
+	}
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if got := parse(t, dir); !got.Equal(date) {
+		t.Errorf("in folder: Scheduled = %v, want %v", got, date)
+	}
+	if got := parse(t, filepath.Dir(file)); !got.IsZero() {
+		t.Errorf("root is the folder: Scheduled = %v, want zero", got)
+	}
+	if got := parse(t, dir); !got.Equal(date) {
+		t.Errorf("cached: Scheduled = %v, want %v", got, date)
+	}
+}
+
+func TestTaskCacheSettings(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache", "tasks.json")
+	file := filepath.Join(dir, "a.md")
+	data := []byte("---\ntasks-notify:\n  recipients: [alice@example.com]\n  tags: [work]\n---\n- [ ] Task 📅 2024-01-15\n")
+	err := os.WriteFile(file, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"not cached", "cached"} {
+		cfg := &tasksConfig{}
+		cache, err := LoadTaskCache(cacheFile, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseFiles(cfg, []string{file}, func(string) ([]byte, error) { return data, nil }, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cache.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := parsed[file].Settings
+		if !slices.Equal(got.Recipients, []string{"alice@example.com"}) || !slices.Equal(got.Tags, []string{"#work"}) {
+			t.Errorf("%s: settings = %+v", name, got)
+		}
+	}
+}
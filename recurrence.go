@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type recurrenceUnit int
+
+const (
+	recurrenceDay recurrenceUnit = iota + 1
+	recurrenceWeek
+	recurrenceMonth
+	recurrenceYear
+)
+
+const lastDayOfMonth = -1
+
+var (
+	errBadRecurrence = errors.New("unsupported recurrence rule")
+
+	reRecurrence      = regexp.MustCompile(`^every (\d+ )?(day|week|month|year)s?(?: on (.+?))?( when done)?$`)
+	reRecurrenceShort = regexp.MustCompile(`^every (weekday|monday|tuesday|wednesday|thursday|friday|saturday|sunday)( when done)?$`)
+	reMonthDay        = regexp.MustCompile(`^the (\d+)(?:st|nd|rd|th)$`)
+	reWeekdaysSep     = regexp.MustCompile(`\s*(?:,|\band\b)\s*`)
+)
+
+// Recurrence is a parsed Obsidian Tasks plugin recurrence rule, like
+// "every 2 weeks on Monday, Friday" or "every month on the last when done".
+type Recurrence struct {
+	Interval int
+	Unit     recurrenceUnit
+	Weekdays map[time.Weekday]bool // Only for Unit == recurrenceWeek.
+	MonthDay int                   // Only for Unit == recurrenceMonth, 0 means same as in task.
+	WhenDone bool
+}
+
+// parseRecurrence parses recurrence rule in format used by Obsidian Tasks plugin.
+func parseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.Join(strings.Fields(strings.ToLower(rule)), " ")
+
+	if m := reRecurrenceShort.FindStringSubmatch(rule); m != nil {
+		r := &Recurrence{Interval: 1, Unit: recurrenceWeek, WhenDone: m[2] != ""}
+		if m[1] == "weekday" {
+			r.Weekdays = map[time.Weekday]bool{
+				time.Monday: true, time.Tuesday: true, time.Wednesday: true, time.Thursday: true, time.Friday: true,
+			}
+		} else {
+			d, _ := parseWeekday(m[1])
+			r.Weekdays = map[time.Weekday]bool{d: true}
+		}
+		return r, nil
+	}
+
+	m := reRecurrence.FindStringSubmatch(rule)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %q", errBadRecurrence, rule)
+	}
+	r := &Recurrence{Interval: 1, WhenDone: m[4] != ""}
+	if m[1] != "" {
+		r.Interval, _ = strconv.Atoi(strings.TrimSpace(m[1]))
+		if r.Interval < 1 {
+			return nil, fmt.Errorf("%w: %q", errBadRecurrence, rule)
+		}
+	}
+	switch m[2] {
+	case "day":
+		r.Unit = recurrenceDay
+	case "week":
+		r.Unit = recurrenceWeek
+	case "month":
+		r.Unit = recurrenceMonth
+	case "year":
+		r.Unit = recurrenceYear
+	}
+
+	on := m[3]
+	switch {
+	case on == "":
+	case r.Unit == recurrenceWeek:
+		r.Weekdays = make(map[time.Weekday]bool)
+		for _, name := range reWeekdaysSep.Split(on, -1) {
+			d, err := parseWeekday(name)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", errBadRecurrence, rule)
+			}
+			r.Weekdays[d] = true
+		}
+	case r.Unit == recurrenceMonth && on == "the last":
+		r.MonthDay = lastDayOfMonth
+	case r.Unit == recurrenceMonth && reMonthDay.MatchString(on):
+		r.MonthDay, _ = strconv.Atoi(reMonthDay.FindStringSubmatch(on)[1])
+		if r.MonthDay < 1 || r.MonthDay > 31 {
+			return nil, fmt.Errorf("%w: %q", errBadRecurrence, rule)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", errBadRecurrence, rule)
+	}
+	return r, nil
+}
+
+// Next returns date of the next occurrence after date.
+func (r *Recurrence) Next(date time.Time) time.Time {
+	switch r.Unit {
+	case recurrenceDay:
+		return date.AddDate(0, 0, r.Interval)
+	case recurrenceWeek:
+		if len(r.Weekdays) == 0 {
+			return date.AddDate(0, 0, 7*r.Interval)
+		}
+		// Weeks start on Monday, only each Interval-th week (starting from the week of date) is used.
+		weekStart := date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+		for d := date.AddDate(0, 0, 1); ; d = d.AddDate(0, 0, 1) {
+			week := daysBetween(weekStart, d) / 7
+			if week%r.Interval == 0 && r.Weekdays[d.Weekday()] {
+				return d
+			}
+		}
+	case recurrenceMonth:
+		day := r.MonthDay
+		if day == 0 {
+			day = date.Day()
+		}
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+		if monthDate(month, day).After(date) && r.MonthDay != 0 {
+			return monthDate(month, day)
+		}
+		return monthDate(month.AddDate(0, r.Interval, 0), day)
+	case recurrenceYear:
+		return date.AddDate(r.Interval, 0, 0)
+	}
+	panic(fmt.Sprintf("unknown recurrence unit %d", r.Unit))
+}
+
+// From returns the rule for calculating occurrences one from another starting at date:
+// month rule without a day gets day of date, so days clamped to shorter months
+// do not drift (Jan 31, Feb 29, Mar 31 instead of Mar 29).
+func (r *Recurrence) From(date time.Time) *Recurrence {
+	if r.Unit != recurrenceMonth || r.MonthDay != 0 {
+		return r
+	}
+	rule := *r
+	rule.MonthDay = date.Day()
+	return &rule
+}
+
+// monthDate returns given day of month, clamped to the last day of month.
+func monthDate(month time.Time, day int) time.Time {
+	last := month.AddDate(0, 1, -1)
+	if day == lastDayOfMonth || day > last.Day() {
+		return last
+	}
+	return month.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"[ ] Task", ""},
+		{"[ ] Task 🔁 every day", "every day"},
+		{"[ ] Task 🔁every week on Monday ⏳ 2024-01-15 📅 2024-01-16", "every week on Monday"},
+		{"[ ] Task 🔁 every month when done #tag ^id", "every month when done"},
+	}
+
+	for _, tt := range tests {
+		tasks, _, err := parseTasks(newMarkdown(), &tasksConfig{}, []byte("- "+tt.line))
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("parseTasks(%q) = %v, %v", tt.line, tasks, err)
+		}
+		if got := tasks[0].Recurrence; got != tt.want {
+			t.Errorf("Recurrence of %q = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		rule    string
+		date    string
+		want    []string
+		wantErr bool
+	}{
+		{rule: "every day", date: "2024-01-15", want: []string{"2024-01-16", "2024-01-17"}},
+		{rule: "every 3 days when done", date: "2024-01-15", want: []string{"2024-01-18", "2024-01-21"}},
+		{rule: "every week", date: "2024-01-15", want: []string{"2024-01-22", "2024-01-29"}},
+		{rule: "every 2 weeks", date: "2024-01-15", want: []string{"2024-01-29"}},
+		{rule: "every weekday", date: "2024-01-18", want: []string{"2024-01-19", "2024-01-22", "2024-01-23"}},
+		{rule: "every Tuesday", date: "2024-01-18", want: []string{"2024-01-23", "2024-01-30"}},
+		{rule: "every week on Monday, Friday", date: "2024-01-16", want: []string{"2024-01-19", "2024-01-22"}},
+		{rule: "every 2 weeks on Monday and Friday", date: "2024-01-16", want: []string{"2024-01-19", "2024-01-29", "2024-02-02"}},
+		{rule: "every Week on monday", date: "2024-01-15", want: []string{"2024-01-22"}},
+		{rule: "every month", date: "2024-01-15", want: []string{"2024-02-15", "2024-03-15"}},
+		{rule: "every month", date: "2024-01-31", want: []string{"2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"}},
+		{rule: "every 2 months when done", date: "2024-12-31", want: []string{"2025-02-28", "2025-04-30"}},
+		{rule: "every month on the 20th", date: "2024-01-15", want: []string{"2024-01-20", "2024-02-20"}},
+		{rule: "every month on the 1st", date: "2024-01-15", want: []string{"2024-02-01"}},
+		{rule: "every 2 months on the last", date: "2024-01-15", want: []string{"2024-01-31", "2024-03-31", "2024-05-31"}},
+		{rule: "every month on the 31st", date: "2024-01-31", want: []string{"2024-02-29", "2024-03-31"}},
+		{rule: "every year", date: "2024-01-15", want: []string{"2025-01-15"}},
+		{rule: "every 0 days", wantErr: true},
+		{rule: "every month on the 32nd", wantErr: true},
+		{rule: "every week on someday", wantErr: true},
+		{rule: "every day on Monday", wantErr: true},
+		{rule: "every January on the 15th", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := parseRecurrence(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRecurrence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			date, _ := time.Parse(time.DateOnly, tt.date)
+			r = r.From(date)
+			for _, want := range tt.want {
+				date = r.Next(date)
+				if got := date.Format(time.DateOnly); got != want {
+					t.Fatalf("Next() = %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
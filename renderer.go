@@ -2,20 +2,17 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"slices"
 	"time"
 
-	mathjax "github.com/litao91/goldmark-mathjax"
 	"github.com/powerman/goldmark-obsidian/obsast"
-	"github.com/yuin/goldmark/ast"
-	extast "github.com/yuin/goldmark/extension/ast"
-	"github.com/yuin/goldmark/renderer"
-	"github.com/yuin/goldmark/util"
-	"go.abhg.dev/goldmark/hashtag"
-	"go.abhg.dev/goldmark/mermaid"
-	"go.abhg.dev/goldmark/wikilink"
 )
 
-// FilteredTasksRenderer implement renderer.NodeRenderer object.
+// Limit amount of upcoming occurrences of a recurring task.
+const maxUpcoming = 1000
+
+// FilteredTasksRenderer renders parsed tasks which match configured filters.
 type FilteredTasksRenderer struct {
 	StatusType            map[obsast.PlugTasksStatusType]bool
 	DueAfter              time.Time
@@ -24,6 +21,13 @@ type FilteredTasksRenderer struct {
 	ScheduledBefore       time.Time
 	StartBefore           time.Time
 	RequireDueOrScheduled bool
+	ExpandRecurring       bool                               // Also render upcoming occurrences of recurring tasks.
+	Tasks                 map[string]TaskRef                 // Tasks with ID from all files, to hide blocked tasks.
+	Blocked               io.Writer                          // If not nil, blocked tasks are rendered here with their blockers.
+	Actions               func(task Task, line int) string   // If not nil, returns links rendered below the task.
+	OnMatch               func(task Task, line int)          // If not nil, called for each rendered not blocked task (line 0 for upcoming).
+	Match                 func(task Task) bool               // If not nil, used instead of configured filters.
+	Filter                func(task Task, blocked bool) bool // If not nil, only matched tasks it accepts are rendered.
 }
 
 // NewActualTasksRenderer returns FilteredTasksRenderer configured to filter tasks:
@@ -31,14 +35,14 @@ type FilteredTasksRenderer struct {
 //   - due or scheduled between dayFrom and dayTo (inclusive)
 //   - without start date or start before today (inclusive)
 //
+// Today is the date of now in now's location.
 // Value 0 for dayFrom and dayTo means today, 1 means tomorrow, -1 means yesterday, etc.
-func NewActualTasksRenderer(dayFrom, dayTo int) renderer.NodeRenderer {
+func NewActualTasksRenderer(now time.Time, dayFrom, dayTo int) *FilteredTasksRenderer {
 	if dayFrom > dayTo {
 		panic(fmt.Sprintf("dayFrom %d must be <= dayTo %d", dayFrom, dayTo))
 	}
 	const day = 24 * time.Hour
-	now := time.Now()
-	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	now = dateOf(now)
 	return &FilteredTasksRenderer{
 		StatusType: map[obsast.PlugTasksStatusType]bool{
 			obsast.PlugTasksStatusTypeTODO:       true,
@@ -53,112 +57,78 @@ func NewActualTasksRenderer(dayFrom, dayTo int) renderer.NodeRenderer {
 	}
 }
 
-// RegisterFuncs add AST objects to Renderer.
-//
-//nolint:funlen // This function naturally needs many statements to register all AST node types
-func (r *FilteredTasksRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
-	// Register render functions for all known kinds to make sure no other functions
-	// (registered with lower priority) will be called and output some HTML.
-	reg.Register(ast.KindAutoLink, nil)
-	reg.Register(ast.KindBlockquote, nil)
-	reg.Register(ast.KindCodeBlock, nil)
-	reg.Register(ast.KindCodeSpan, nil)
-	reg.Register(ast.KindDocument, nil)
-	reg.Register(ast.KindEmphasis, nil)
-	reg.Register(ast.KindFencedCodeBlock, nil)
-	reg.Register(ast.KindHTMLBlock, nil)
-	reg.Register(ast.KindHeading, nil)
-	reg.Register(ast.KindImage, nil)
-	reg.Register(ast.KindLink, nil)
-	reg.Register(ast.KindList, nil)
-	reg.Register(ast.KindListItem, r.listItem)
-	reg.Register(ast.KindParagraph, nil)
-	reg.Register(ast.KindRawHTML, nil)
-	reg.Register(ast.KindString, nil)
-	reg.Register(ast.KindText, nil)
-	reg.Register(ast.KindTextBlock, nil)
-	reg.Register(ast.KindThematicBreak, nil)
-
-	reg.Register(extast.KindDefinitionDescription, nil)
-	reg.Register(extast.KindDefinitionList, nil)
-	reg.Register(extast.KindDefinitionTerm, nil)
-	reg.Register(extast.KindFootnote, nil)
-	reg.Register(extast.KindFootnoteBacklink, nil)
-	reg.Register(extast.KindFootnoteLink, nil)
-	reg.Register(extast.KindFootnoteList, nil)
-	reg.Register(extast.KindStrikethrough, nil)
-	reg.Register(extast.KindTable, nil)
-	reg.Register(extast.KindTableCell, nil)
-	reg.Register(extast.KindTableHeader, nil)
-	reg.Register(extast.KindTableRow, nil)
-	reg.Register(extast.KindTaskCheckBox, nil)
-
-	reg.Register(hashtag.Kind, nil)
-
-	reg.Register(wikilink.Kind, nil)
-
-	reg.Register(mermaid.Kind, nil)
-	reg.Register(mermaid.ScriptKind, nil)
-
-	reg.Register(mathjax.KindMathBlock, nil)
-	reg.Register(mathjax.KindInlineMath, nil)
-
-	reg.Register(obsast.KindBlockID, nil)
-
-	reg.Register(obsast.KindPlugTasksStatus, nil)
-	reg.Register(obsast.KindPlugTasksPrio, nil)
-	reg.Register(obsast.KindPlugTasksID, nil)
-	reg.Register(obsast.KindPlugTasksDependsOn, nil)
-	reg.Register(obsast.KindPlugTasksDue, nil)
-	reg.Register(obsast.KindPlugTasksScheduled, nil)
-	reg.Register(obsast.KindPlugTasksStart, nil)
-	reg.Register(obsast.KindPlugTasksCreated, nil)
-	reg.Register(obsast.KindPlugTasksDone, nil)
-	reg.Register(obsast.KindPlugTasksCancelled, nil)
-	reg.Register(obsast.KindPlugTasksRecurring, nil)
-	reg.Register(obsast.KindPlugTasksOnCompletion, nil)
-}
-
-// listItem renders a list item node, but only if it matches the configured filters.
-// The entering parameter indicates whether we're entering (true) or leaving (false) the node.
-//
-//nolint:revive // entering parameter is required by goldmark AST walker interface
-func (r *FilteredTasksRenderer) listItem(w util.BufWriter, source []byte, node ast.Node, entering bool) (
-	ast.WalkStatus, error,
-) {
-	n := node.(*ast.ListItem)
-	if !entering {
-		return ast.WalkContinue, nil
+// render writes the task at given line and its upcoming occurrences to w if they
+// match the configured filters, or to r.Blocked if the task is blocked.
+func (r *FilteredTasksRenderer) render(w io.Writer, task Task, line int) {
+	type occurrence struct {
+		task Task
+		line int // Upcoming occurrences are not in source and have line 0.
+	}
+	var matched []occurrence // The task and its upcoming occurrences.
+	if r.match(task) {
+		matched = append(matched, occurrence{task, line})
+	}
+	if r.ExpandRecurring {
+		for _, date := range r.upcoming(task) {
+			next := task.Shift(daysBetween(task.ReferenceDate(), date))
+			next.Line = fmt.Sprintf("%s (upcoming %s)", task.Line, date.Format(time.DateOnly))
+			matched = append(matched, occurrence{next, 0})
+		}
 	}
-	if n.FirstChild() == nil {
-		return ast.WalkContinue, nil
+	blockers := r.blockers(task)
+	if len(blockers) > 0 && r.Blocked == nil {
+		return
+	}
+	if r.Filter != nil {
+		matched = slices.DeleteFunc(matched, func(o occurrence) bool { return !r.Filter(o.task, len(blockers) > 0) })
+	}
+	if len(matched) == 0 {
+		return
 	}
 
-	var task struct {
-		StatusType obsast.PlugTasksStatusType
-		Due        time.Time
-		Scheduled  time.Time
-		Start      time.Time
-	}
-	err := ast.Walk(n.FirstChild(), func(n ast.Node, _ bool) (ast.WalkStatus, error) {
-		switch n := n.(type) {
-		case *ast.List:
-			return ast.WalkSkipChildren, nil
-		case *obsast.PlugTasksStatus:
-			task.StatusType = n.StatusType
-		case *obsast.PlugTasksDue:
-			task.Due = n.Date
-		case *obsast.PlugTasksScheduled:
-			task.Scheduled = n.Date
-		case *obsast.PlugTasksStart:
-			task.Start = n.Date
+	if len(blockers) == 0 {
+		for _, o := range matched {
+			_, _ = fmt.Fprintf(w, "- %s\n", o.task.Line)
+			if r.OnMatch != nil {
+				r.OnMatch(o.task, o.line)
+			}
+			if o.line != 0 && r.Actions != nil {
+				if links := r.Actions(o.task, o.line); links != "" {
+					_, _ = fmt.Fprintf(w, "  %s\n", links)
+				}
+			}
+		}
+	} else {
+		for _, o := range matched {
+			_, _ = fmt.Fprintf(r.Blocked, "- %s\n", o.task.Line)
+		}
+		for _, blocker := range blockers {
+			_, _ = fmt.Fprintf(r.Blocked, "    ⛔ %s", blocker.Task.Line)
+			if blocker.File != "" {
+				_, _ = fmt.Fprintf(r.Blocked, " (%s)", blocker.File)
+			}
+			_, _ = fmt.Fprintln(r.Blocked)
+		}
+	}
+}
+
+// blockers returns not done tasks the task depends on.
+// Unknown task IDs are ignored, like Obsidian Tasks plugin does.
+func (r *FilteredTasksRenderer) blockers(task Task) []TaskRef {
+	var blockers []TaskRef
+	for _, id := range task.DependsOn {
+		blocker, ok := r.Tasks[id]
+		if ok && isOpen(blocker.Task) {
+			blockers = append(blockers, blocker)
 		}
-		return ast.WalkContinue, nil
-	})
-	if err != nil {
-		return 0, err
 	}
+	return blockers
+}
 
+func (r *FilteredTasksRenderer) match(task Task) bool {
+	if r.Match != nil {
+		return r.Match(task)
+	}
 	var (
 		hasDue          = !task.Due.IsZero()
 		hasScheduled    = !task.Scheduled.IsZero()
@@ -166,19 +136,58 @@ func (r *FilteredTasksRenderer) listItem(w util.BufWriter, source []byte, node a
 		scheduledInTime = isBetween(task.Scheduled, r.ScheduledBefore, r.ScheduledAfter)
 		started         = isBetween(task.Start, r.StartBefore, time.Time{})
 	)
-	if r.StatusType[task.StatusType] &&
+	return r.StatusType[task.StatusType] &&
 		started &&
 		((hasDue && hasScheduled && (dueInTime || scheduledInTime)) ||
 			(dueInTime && scheduledInTime)) &&
-		(!r.RequireDueOrScheduled || hasDue || hasScheduled) {
-		seg := n.FirstChild().Lines().At(0)
-		_ = w.WriteByte('-')
-		_ = w.WriteByte(' ')
-		_, _ = w.Write(seg.Value(source))
-		_ = w.WriteByte('\n')
+		(!r.RequireDueOrScheduled || hasDue || hasScheduled)
+}
+
+// upcoming returns dates of future occurrences of recurring task which match filters.
+// Dates are calculated in the same way as Obsidian Tasks plugin does: from due date,
+// or scheduled date, or start date; other dates are moved by the same amount of days.
+// Rules with "when done" are calculated as if the task is done today.
+func (r *FilteredTasksRenderer) upcoming(task Task) []time.Time {
+	if task.Recurrence == "" {
+		return nil
+	}
+	rec, err := parseRecurrence(task.Recurrence)
+	if err != nil {
+		return nil
+	}
+	ref := task.ReferenceDate()
+	until := r.DueBefore
+	if r.ScheduledBefore.After(until) {
+		until = r.ScheduledBefore
+	}
+	if ref.IsZero() || until.IsZero() {
+		return nil
 	}
 
-	return ast.WalkContinue, nil
+	var dates []time.Time
+	date := ref
+	if rec.WhenDone {
+		date = r.StartBefore // Today.
+	}
+	rec = rec.From(date)
+	// Occurrences before the window are stepped over too, so amount of steps is
+	// bounded by the window end (Next always moves forward), not by maxUpcoming.
+	for len(dates) < maxUpcoming {
+		date = rec.Next(date)
+		if date.After(until) {
+			break
+		}
+		if date.Before(r.StartBefore) {
+			continue
+		}
+		if r.match(task.Shift(daysBetween(ref, date))) {
+			dates = append(dates, date)
+		}
+		if rec.WhenDone {
+			break
+		}
+	}
+	return dates
 }
 
 // All checks are optional, interval is inclusive at both sides.
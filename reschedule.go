@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+)
+
+var (
+	errBadSnooze = errors.New("bad snooze duration")
+
+	reSnooze = regexp.MustCompile(`^(\d+)([dwm])$`)
+)
+
+// snoozeMain implements "snooze" command and returns exit code.
+func snoozeMain(args []string) int {
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s snooze [flags] TASK DURATION [PATH ...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(fs.Output(), "Postpone task by DURATION (like 3d, 2w or 1m): its ⏳ scheduled date or, if missing, 📅 due date.")
+		_, _ = fmt.Fprintln(fs.Output(), "Date in the past is postponed relative to today. Task without dates gets ⏳ date.")
+		_, _ = fmt.Fprintln(fs.Output(), "TASK is 🆔 of the task, its block ID (^id) or FILE:LINE, see done command.")
+		_, _ = fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	opts, now := editFlags(fs)
+	var cfg config
+	fileFlags(fs, &cfg.Files)
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+	cfg.Tasks.load(fs.Args()[2:])
+
+	err := runSnooze(&cfg, now(), fs.Arg(0), fs.Arg(1), *opts, os.Stdout, fs.Args()[2:])
+	if err != nil {
+		log.Fatalln("Failed to", err)
+	}
+	return 0
+}
+
+// rescheduleOverdueMain implements "reschedule-overdue" command and returns exit code.
+func rescheduleOverdueMain(args []string) int {
+	fs := flag.NewFlagSet("reschedule-overdue", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s reschedule-overdue [flags] [PATH ...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(fs.Output(), "Move ⏳ scheduled and 📅 due dates in the past of not done tasks to another day.")
+		_, _ = fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	to := fs.String("to", "today", "Move overdue dates to this day: today, tomorrow or YYYY-MM-DD")
+	opts, now := editFlags(fs)
+	var cfg config
+	fileFlags(fs, &cfg.Files)
+	_ = fs.Parse(args)
+	cfg.Tasks.load(fs.Args())
+
+	err := runRescheduleOverdue(&cfg, now(), *to, *opts, os.Stdout, fs.Args())
+	if err != nil {
+		log.Fatalln("Failed to", err)
+	}
+	return 0
+}
+
+// editFlags defines flags common for commands which change tasks.
+// Returned now must be called after parsing flags.
+func editFlags(fs *flag.FlagSet) (opts *editOptions, now func() time.Time) {
+	opts = &editOptions{}
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Output diff instead of changing files")
+	fs.BoolVar(&opts.Backup, "backup", false, "Keep original files with .bak suffix")
+	date := fs.String("date", "", "Use this date (YYYY-MM-DD) as today")
+	tz := fs.String("tz", "", "Use this timezone (e.g. Europe/Kyiv) to detect today (default local)")
+	return opts, func() time.Time {
+		t, err := parseNow(*date, *tz)
+		if err != nil {
+			log.Fatalln("Error:", err)
+		}
+		return t
+	}
+}
+
+// parseSnooze parses duration like 3d, 2w or 1m into a function which adds it to a date.
+func parseSnooze(s string) (func(time.Time) time.Time, error) {
+	m := reSnooze.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return nil, fmt.Errorf("%w: %q", errBadSnooze, s)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("%w: %q", errBadSnooze, s)
+	}
+	return func(date time.Time) time.Time {
+		switch m[2] {
+		case "w":
+			return date.AddDate(0, 0, 7*n)
+		case "m":
+			return date.AddDate(0, n, 0)
+		default:
+			return date.AddDate(0, 0, n)
+		}
+	}, nil
+}
+
+// runSnooze postpones task referenced by ref by duration.
+func runSnooze(cfg *config, now time.Time, ref, duration string, opts editOptions, stdout io.Writer, paths []string) error {
+	add, err := parseSnooze(duration)
+	if err != nil {
+		return err
+	}
+	filename, line, err := findTask(cfg, ref, paths)
+	if err != nil {
+		return fmt.Errorf("find task %q: %w", ref, err)
+	}
+	_, err = snoozeTaskInFile(&cfg.Tasks, filename, line, add, dateOf(now), opts, stdout)
+	if err != nil {
+		return fmt.Errorf("snooze task %q: %w", ref, err)
+	}
+	return nil
+}
+
+// snoozeTaskInFile postpones task at given line of the file using add and returns changed line.
+func snoozeTaskInFile(
+	cfg *tasksConfig, filename string, line int, add func(time.Time) time.Time, today time.Time, opts editOptions, stdout io.Writer,
+) (string, error) {
+	var changed string
+	err := editFile(filename, opts, stdout, func(source []byte) ([]taskEdit, error) {
+		task, err := openTaskAt(cfg, source, line)
+		if err != nil {
+			return nil, err
+		}
+		emoji, date := "⏳", task.Scheduled
+		if date.IsZero() && !task.Due.IsZero() {
+			emoji, date = "📅", task.Due
+		}
+		if date.Before(today) {
+			date = today
+		}
+		old := strings.TrimRight(task.Line, " \t\r\n")
+		changed = setTaskDate(old, emoji, add(date))
+		return []taskEdit{{Offset: task.Offset, Old: old, New: changed}}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return changed, nil
+}
+
+// runRescheduleOverdue moves dates in the past of not done tasks to the day to.
+func runRescheduleOverdue(cfg *config, now time.Time, to string, opts editOptions, stdout io.Writer, paths []string) error {
+	today := dateOf(now)
+	day, _, err := parseWindow(to, now, time.Monday, nil)
+	if err != nil {
+		return fmt.Errorf("bad -to: %w", err)
+	}
+	target := today.AddDate(0, 0, day)
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := cfg.Files.readMarkdownFiles(paths)
+	if err != nil {
+		return err
+	}
+	md := newMarkdown()
+	for _, filename := range slices.Sorted(maps.Keys(files)) {
+		err = editFile(filename, opts, stdout, func(source []byte) ([]taskEdit, error) {
+			return rescheduleOverdue(md, &cfg.Tasks, source, today, target)
+		})
+		if err != nil {
+			return fmt.Errorf("reschedule %q: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// rescheduleOverdue returns edits which move ⏳ and 📅 dates before today to target.
+func rescheduleOverdue(md goldmark.Markdown, cfg *tasksConfig, source []byte, today, target time.Time) ([]taskEdit, error) {
+	tasks, _, err := parseTasks(md, cfg, source)
+	if err != nil {
+		return nil, err
+	}
+	var edits []taskEdit
+	for _, task := range tasks {
+		if !isOpen(task) {
+			continue
+		}
+		old := strings.TrimRight(task.Line, " \t\r\n")
+		line := old
+		if !task.Scheduled.IsZero() && task.Scheduled.Before(today) {
+			line = setTaskDate(line, "⏳", target)
+		}
+		if !task.Due.IsZero() && task.Due.Before(today) {
+			line = setTaskDate(line, "📅", target)
+		}
+		if line != old {
+			edits = append(edits, taskEdit{Offset: task.Offset, Old: old, New: line})
+		}
+	}
+	return edits, nil
+}
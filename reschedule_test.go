@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunSnooze(t *testing.T) {
+	now := time.Date(2024, 1, 17, 9, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		source   string
+		duration string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Scheduled in future",
+			source:   "- [ ] Task ⏳ 2024-01-20 📅 2024-01-25\n",
+			duration: "3d",
+			want:     "- [ ] Task ⏳ 2024-01-23 📅 2024-01-25\n",
+		},
+		{
+			name:     "Due in past",
+			source:   "- [/] Task 📅 2024-01-10 ^abc\n",
+			duration: "1w",
+			want:     "- [/] Task 📅 2024-01-24 ^abc\n",
+		},
+		{
+			name:     "No dates",
+			source:   "- [ ] Task ^abc  \n",
+			duration: "1m",
+			want:     "- [ ] Task ⏳ 2024-02-17 ^abc  \n",
+		},
+		{
+			name:     "Dataview due",
+			source:   "- [ ] Task [due:: 2024-01-20] ^abc\n",
+			duration: "3d",
+			want:     "- [ ] Task [due:: 2024-01-23] ^abc\n",
+		},
+		{
+			name:     "Dataview without dates",
+			source:   "- [ ] Task [priority:: high]\n",
+			duration: "1d",
+			want:     "- [ ] Task [priority:: high] [scheduled:: 2024-01-18]\n",
+		},
+		{
+			name:     "Bad duration",
+			source:   "- [ ] Task\n",
+			duration: "1y",
+			wantErr:  true,
+		},
+		{
+			name:     "Done",
+			source:   "- [x] Task ✅ 2024-01-10\n",
+			duration: "1d",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.md")
+			err := os.WriteFile(path, []byte(tt.source), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stdout bytes.Buffer
+			err = runSnooze(&config{}, now, path+":1", tt.duration, editOptions{}, &stdout, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runSnooze() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRescheduleOverdue(t *testing.T) {
+	now := time.Date(2024, 1, 17, 9, 0, 0, 0, time.Local)
+	source := "# Tasks\n\n" +
+		"- [ ] Overdue 📅 2024-01-10\n" +
+		"  - [ ] Both ⏳ 2024-01-12 📅 2024-01-20 ^id\n" +
+		"- [x] Done 📅 2024-01-10 ✅ 2024-01-10\n" +
+		"- [ ] Today ⏳ 2024-01-17\n"
+	want := "# Tasks\n\n" +
+		"- [ ] Overdue 📅 2024-01-18\n" +
+		"  - [ ] Both ⏳ 2024-01-18 📅 2024-01-20 ^id\n" +
+		"- [x] Done 📅 2024-01-10 ✅ 2024-01-10\n" +
+		"- [ ] Today ⏳ 2024-01-17\n"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	err := os.WriteFile(path, []byte(source), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	err = runRescheduleOverdue(&config{}, now, "tomorrow", editOptions{DryRun: true}, &stdout, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDiff := "--- " + path + "\n+++ " + path + "\n" +
+		"@@ -3 +3 @@\n-- [ ] Overdue 📅 2024-01-10\n+- [ ] Overdue 📅 2024-01-18\n" +
+		"@@ -4 +4 @@\n-  - [ ] Both ⏳ 2024-01-12 📅 2024-01-20 ^id\n+  - [ ] Both ⏳ 2024-01-18 📅 2024-01-20 ^id\n"
+	if stdout.String() != wantDiff {
+		t.Errorf("diff =\n%s\nwant:\n%s", stdout.String(), wantDiff)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != source {
+		t.Errorf("file changed in dry run mode")
+	}
+
+	stdout.Reset()
+	err = runRescheduleOverdue(&config{}, now, "tomorrow", editOptions{Backup: true}, &stdout, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("file =\n%s\nwant:\n%s", got, want)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != source {
+		t.Errorf("backup =\n%s\nwant:\n%s", backup, source)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const serveReadHeaderTimeout = 10 * time.Second
+
+// serveMain implements "serve" command and returns exit code.
+func serveMain(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] [PATH ...]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(fs.Output(), "Run HTTP server with a dashboard of tasks in PATHs (default current directory).")
+		_, _ = fmt.Fprintln(fs.Output(), "With -actions it also handles action links (Done, Snooze) from notifications,")
+		_, _ = fmt.Fprintln(fs.Output(), "environment variable ACTION_SECRET must be the same as used to send notifications.")
+		_, _ = fmt.Fprintln(fs.Output(), "If environment variable CALENDAR_TOKEN is set, it also serves iCalendar feed at")
+		_, _ = fmt.Fprintln(fs.Output(), "/calendar.ics?token=CALENDAR_TOKEN.")
+		_, _ = fmt.Fprintln(fs.Output(), "If environment variable CALDAV_PASSWORD is set, it also serves CalDAV at /caldav/")
+		_, _ = fmt.Fprintln(fs.Output(), "(user name is CALDAV_USER, any if not set), which allows changing tasks.")
+		_, _ = fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	addr := fs.String("http", ":8080", "Listen on this address")
+	window := fs.String("window", "today", "Default days to include, see -window of the main command")
+	weekStart := fs.String("week-start", "monday", "First day of the week for -window")
+	withActions := fs.Bool("actions", false, "Allow changing tasks using action links and dashboard buttons")
+	maxFileSize := fs.Int64("max-file-size", defaultMaxFileSize, "Skip Markdown files larger than this amount of bytes (0 for no limit)")
+	usedFile := fs.String("used-actions", defaultUsedActionsFile(), "Remember used action links in this file (empty to not remember after restart)")
+	var cfg config
+	fileFlags(fs, &cfg.Files)
+	tasksConfigFlags(fs, &cfg.Tasks)
+	_ = fs.Parse(args)
+
+	ws, err := parseWeekday(*weekStart)
+	if err != nil {
+		log.Fatalln("Error: bad -week-start:", err)
+	}
+	d := &Dashboard{
+		Paths:         fs.Args(),
+		Window:        *window,
+		WeekStart:     ws,
+		Now:           time.Now,
+		CalendarToken: os.Getenv("CALENDAR_TOKEN"),
+		MaxFileSize:   *maxFileSize,
+	}
+	if len(d.Paths) == 0 {
+		d.Paths = []string{"."}
+	}
+	cfg.Tasks.load(d.Paths)
+	d.Config = cfg
+	d.CalDAV = NewCalDAVFromEnv(d.Paths, cfg, d.MaxFileSize)
+	_, _, err = parseWindow(d.Window, d.Now(), d.WeekStart, nil)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	if *withActions {
+		d.Actions, err = NewActionsFromEnv()
+		if err != nil {
+			log.Fatalln("Error:", err)
+		}
+		if d.Actions == nil {
+			log.Fatalln("Error: ACTION_SECRET is required for -actions")
+		}
+		d.Actions.UsedFile = *usedFile
+		d.Actions.Tasks = cfg.Tasks
+	}
+
+	err = runServe(*addr, d)
+	if err != nil {
+		log.Fatalln("Failed to", err)
+	}
+	return 0
+}
+
+// runServe runs HTTP server until it fails.
+func runServe(addr string, d *Dashboard) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           newServeMux(d),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+	}
+	log.Printf("Listening on %s.", addr)
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+func newServeMux(d *Dashboard) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/", d)
+	mux.HandleFunc("/events", d.ServeEvents)
+	mux.HandleFunc("/api/tasks", d.ServeAPITasks)
+	mux.HandleFunc("/api/files", d.ServeAPIFiles)
+	mux.HandleFunc("/api/tags", d.ServeAPITags)
+	if d.Actions != nil {
+		mux.Handle("/action", d.Actions)
+	}
+	if d.CalendarToken != "" {
+		mux.HandleFunc("/calendar.ics", d.ServeCalendar)
+	}
+	if d.CalDAV != nil {
+		mux.Handle(caldavPath, d.CalDAV)
+		mux.Handle("/.well-known/caldav", http.RedirectHandler(caldavPath, http.StatusMovedPermanently))
+	}
+	return mux
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	obsidian "github.com/powerman/goldmark-obsidian"
+	"github.com/powerman/goldmark-obsidian/obsast"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	reTaskID        = regexp.MustCompile(`🆔\s*([\w-]+)`)
+	reTaskDependsOn = regexp.MustCompile(`⛔\s*([\w-]+(?:\s*,\s*[\w-]+)*)`)
+	reTaskIDSep     = regexp.MustCompile(`\s*,\s*`)
+)
+
+// Task contains properties of a task used by filters.
+type Task struct {
+	Line       string // First line of the task without list marker.
+	Offset     int    // Offset of Line in Markdown source.
+	IsTask     bool   // List item has a status (checkbox).
+	StatusType obsast.PlugTasksStatusType
+	Due        time.Time
+	Scheduled  time.Time
+	Start      time.Time
+	Recurrence string   // Recurrence rule, e.g. "every week on Monday".
+	Priority   string   // Name of priority (see priorities) or empty if not set.
+	ID         string   // Task ID (🆔).
+	DependsOn  []string // IDs of tasks which must be done before this one (⛔).
+}
+
+// TaskRef is a task with name of the file containing it.
+type TaskRef struct {
+	File string
+	Task Task
+}
+
+// Shift returns copy of the task with all dates moved by days.
+func (t Task) Shift(days int) Task {
+	for _, date := range []*time.Time{&t.Due, &t.Scheduled, &t.Start} {
+		if !date.IsZero() {
+			*date = date.AddDate(0, 0, days)
+		}
+	}
+	return t
+}
+
+// isOpen reports whether task is not done or cancelled.
+func isOpen(task Task) bool {
+	return task.StatusType == obsast.PlugTasksStatusTypeTODO || task.StatusType == obsast.PlugTasksStatusTypeInProgress
+}
+
+// ReferenceDate returns date used to calculate next occurrence of recurring task:
+// due date, scheduled date or start date (first non-zero), like Obsidian Tasks plugin does.
+func (t Task) ReferenceDate() time.Time {
+	for _, date := range []time.Time{t.Due, t.Scheduled, t.Start} {
+		if !date.IsZero() {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// lineOf returns 1-based number of line containing offset.
+func lineOf(source []byte, offset int) int {
+	return bytes.Count(source[:offset], []byte("\n")) + 1
+}
+
+// newMarkdown returns Markdown parser with extensions needed to parse tasks.
+func newMarkdown(opts ...goldmark.Option) goldmark.Markdown {
+	return goldmark.New(append([]goldmark.Option{
+		goldmark.WithExtensions(
+			obsidian.NewPlugTasks(),
+			obsidian.NewObsidian(),
+		),
+	}, opts...)...)
+}
+
+// parseTask returns properties of a task in list item n, using cfg to decide if it is a task.
+// List item must have non-empty first child.
+func parseTask(cfg *tasksConfig, n *ast.ListItem, source []byte) (Task, error) {
+	seg := n.FirstChild().Lines().At(0)
+	task := Task{Line: string(seg.Value(source)), Offset: seg.Start}
+	err := ast.Walk(n.FirstChild(), func(n ast.Node, _ bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.List:
+			return ast.WalkSkipChildren, nil
+		case *obsast.PlugTasksStatus:
+			task.IsTask = true
+			task.StatusType = n.StatusType
+		case *obsast.PlugTasksDue:
+			task.Due = n.Date
+		case *obsast.PlugTasksScheduled:
+			task.Scheduled = n.Date
+		case *obsast.PlugTasksStart:
+			task.Start = n.Date
+		case *obsast.PlugTasksRecurring:
+			task.Recurrence = strings.TrimSpace(strings.TrimPrefix(nodeText(n, source), "🔁"))
+		case *obsast.PlugTasksID:
+			if m := reTaskID.FindStringSubmatch(task.Line); m != nil {
+				task.ID = m[1]
+			}
+		case *obsast.PlugTasksDependsOn:
+			if m := reTaskDependsOn.FindStringSubmatch(task.Line); m != nil {
+				task.DependsOn = reTaskIDSep.Split(m[1], -1)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, p := range priorities {
+		if p.Emoji != "" && strings.Contains(task.Line, p.Emoji) {
+			task.Priority = p.Name
+			break
+		}
+	}
+	if task.IsTask {
+		parseDataviewFields(&task)
+	}
+	cfg.apply(&task)
+	return task, err
+}
+
+// nodeText returns concatenated text of all text nodes inside n.
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := n.(*ast.Text); ok && entering {
+			b.Write(t.Segment.Value(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// parseListItems returns all non-empty list items in Markdown source.
+func parseListItems(md goldmark.Markdown, cfg *tasksConfig, source []byte, opts ...parser.ParseOption) ([]Task, error) {
+	doc := md.Parser().Parse(text.NewReader(source), opts...)
+	var items []Task
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		li, ok := n.(*ast.ListItem)
+		if !entering || !ok || li.FirstChild() == nil || li.FirstChild().Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+		task, err := parseTask(cfg, li, source)
+		if err == nil {
+			items = append(items, task)
+		}
+		return ast.WalkContinue, err
+	})
+	return items, err
+}
+
+// parseTasks returns all tasks (list items with a status) in Markdown source
+// and settings from its frontmatter.
+func parseTasks(md goldmark.Markdown, cfg *tasksConfig, source []byte) ([]Task, noteSettings, error) {
+	pc := parser.NewContext()
+	items, err := parseListItems(md, cfg, source, parser.WithContext(pc))
+	if err != nil {
+		return nil, noteSettings{}, err
+	}
+	var tasks []Task
+	for _, item := range items {
+		if item.IsTask {
+			tasks = append(tasks, item)
+		}
+	}
+	return tasks, noteSettingsOf(pc), nil
+}
+
+// indexTasks returns tasks with ID from all files. If ID is duplicated then
+// the first task (in order of filenames and lines) is used, like lint does,
+// and other tasks are reported with a warning.
+func indexTasks(files map[string]parsedFile) map[string]TaskRef {
+	index := make(map[string]TaskRef)
+	lines := make(map[string]int) // ID -> line of indexed task.
+	for _, filename := range slices.Sorted(maps.Keys(files)) {
+		for _, t := range files[filename].Tasks {
+			if t.Task.ID == "" {
+				continue
+			}
+			if first, ok := index[t.Task.ID]; ok {
+				log.Printf("Warning: Ignoring duplicate 🆔 %s at %s:%d, first at %s:%d",
+					t.Task.ID, filename, t.Line, first.File, lines[t.Task.ID])
+				continue
+			}
+			index[t.Task.ID] = TaskRef{File: filename, Task: t.Task}
+			lines[t.Task.ID] = t.Line
+		}
+	}
+	return index
+}
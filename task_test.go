@@ -0,0 +1,68 @@
+package main
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestIndexTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		want  map[string][]string // ID -> file, DependsOn...
+	}{
+		{
+			name: "No dependencies",
+			files: map[string][]byte{
+				"a.md": []byte("- [ ] Task 🆔 a\n"),
+			},
+			want: map[string][]string{
+				"a": {"a.md"},
+			},
+		},
+		{
+			name: "Dependencies",
+			files: map[string][]byte{
+				"a.md": []byte("- [ ] Task A 🆔 a ⛔ b,c\n- Not a task 🆔 x\n"),
+				"b.md": []byte("- [x] Task B 🆔 b\n  - [ ] Nested 🆔 c ⛔ b\n- [ ] Without ID ⛔ a\n"),
+			},
+			want: map[string][]string{
+				"a": {"a.md", "b", "c"},
+				"b": {"b.md"},
+				"c": {"b.md", "b"},
+			},
+		},
+		{
+			name: "Duplicate ID",
+			files: map[string][]byte{
+				"c.md": []byte("- [ ] Task C 🆔 a\n"),
+				"a.md": []byte("- [ ] Task A 🆔 a ⛔ b\n- [ ] Task A2 🆔 a\n"),
+				"b.md": []byte("- [ ] Task B 🆔 a\n"),
+			},
+			want: map[string][]string{
+				"a": {"a.md", "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseFiles(&tasksConfig{}, slices.Collect(maps.Keys(tt.files)), func(filename string) ([]byte, error) {
+				return tt.files[filename], nil
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := indexTasks(parsed)
+			got := make(map[string][]string)
+			for id, ref := range index {
+				got[id] = append([]string{ref.File}, ref.Task.DependsOn...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("indexTasks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
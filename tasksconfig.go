@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/powerman/goldmark-obsidian/obsast"
+)
+
+// tasksPluginConfig is a path to Obsidian Tasks plugin settings inside a vault.
+const tasksPluginConfig = ".obsidian/plugins/obsidian-tasks-plugin/data.json"
+
+// tasksPluginStatusTypes maps status types used by Obsidian Tasks plugin.
+var tasksPluginStatusTypes = map[string]obsast.PlugTasksStatusType{
+	"TODO":        obsast.PlugTasksStatusTypeTODO,
+	"IN_PROGRESS": obsast.PlugTasksStatusTypeInProgress,
+	"DONE":        obsast.PlugTasksStatusTypeDone,
+	"CANCELLED":   obsast.PlugTasksStatusTypeCancelled,
+	"NON_TASK":    obsast.PlugTasksStatusTypeNonTask,
+}
+
+// tasksConfig contains settings of Obsidian Tasks plugin which change what is a task.
+type tasksConfig struct {
+	GlobalFilter        string                                // If not empty, only checkboxes containing it are tasks.
+	Statuses            map[string]obsast.PlugTasksStatusType // Status symbol -> type.
+	FilenameDate        bool                                  // Use date in filename as scheduled date of tasks without dates.
+	FilenameDateFolders []string                              // If not empty, FilenameDate is used only in these folders.
+	FilenameDateFormats []string                              // Formats of date in filename (default YYYY-MM-DD and YYYYMMDD).
+
+	filenameDateFormats []filenameDateFormat // Compiled FilenameDateFormats.
+	roots               []string             // Vaults or PATHs (absolute), FilenameDateFolders are relative to them.
+}
+
+// filenameDateFormat is a compiled format of date in filename.
+type filenameDateFormat struct {
+	re     *regexp.Regexp // Matches date in group 1.
+	layout string         // Time layout to parse matched date.
+}
+
+// Formats of dates in daily notes filenames recognized by Obsidian Tasks plugin.
+var defaultFilenameDateFormats = []filenameDateFormat{
+	newFilenameDateFormat("YYYY-MM-DD"),
+	newFilenameDateFormat("YYYYMMDD"),
+}
+
+// tasksConfigFlags defines flags which set c.
+func tasksConfigFlags(fs *flag.FlagSet, c *tasksConfig) {
+	fs.BoolVar(&c.FilenameDate, "filename-date", false,
+		"Use date in filename (like in daily notes) as scheduled date of tasks without dates")
+	fs.Func("filename-date-folder", "Use -filename-date only for files in this folder (can be repeated)", func(folder string) error {
+		c.FilenameDateFolders = append(c.FilenameDateFolders, folder)
+		return nil
+	})
+	fs.Func("filename-date-format", "Format of date in filename for -filename-date, using YYYY, MM and DD\n"+
+		"(can be repeated, default YYYY-MM-DD and YYYYMMDD)", c.addFilenameDateFormat)
+}
+
+// addFilenameDateFormat adds format of date in filename, using YYYY, MM and DD.
+func (c *tasksConfig) addFilenameDateFormat(format string) error {
+	if !strings.Contains(format, "YYYY") || !strings.Contains(format, "MM") || !strings.Contains(format, "DD") {
+		return errors.New("must contain YYYY, MM and DD")
+	}
+	c.FilenameDateFormats = append(c.FilenameDateFormats, format)
+	c.filenameDateFormats = append(c.filenameDateFormats, newFilenameDateFormat(format))
+	return nil
+}
+
+// tasksPluginData is a part of Obsidian Tasks plugin settings file.
+type tasksPluginData struct {
+	GlobalFilter               string   `json:"globalFilter"`
+	UseFilenameAsScheduledDate bool     `json:"useFilenameAsScheduledDate"`
+	FilenameAsDateFolders      []string `json:"filenameAsDateFolders"`
+	StatusSettings             struct {
+		CoreStatuses   []tasksPluginStatus `json:"coreStatuses"`
+		CustomStatuses []tasksPluginStatus `json:"customStatuses"`
+	} `json:"statusSettings"`
+}
+
+type tasksPluginStatus struct {
+	Symbol string `json:"symbol"`
+	Type   string `json:"type"`
+}
+
+// load adds to c Obsidian Tasks plugin settings from vaults containing paths
+// (current directory if paths are empty). Vaults (or paths outside of vaults)
+// become roots for FilenameDateFolders.
+func (c *tasksConfig) load(paths []string) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		vault := findVault(path)
+		if vault == "" {
+			c.addRoot(path)
+			continue
+		}
+		if seen[vault] {
+			continue
+		}
+		seen[vault] = true
+		c.addRoot(vault)
+
+		filename := filepath.Join(vault, filepath.FromSlash(tasksPluginConfig))
+		data, err := os.ReadFile(filename) //nolint:gosec // Path is provided by user.
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var settings tasksPluginData
+		if err == nil {
+			err = json.Unmarshal(data, &settings)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to load Obsidian Tasks settings: %v", err)
+			continue
+		}
+		c.add(settings, filename)
+	}
+}
+
+func (c *tasksConfig) add(settings tasksPluginData, filename string) {
+	switch {
+	case settings.GlobalFilter == "":
+	case c.GlobalFilter == "":
+		c.GlobalFilter = settings.GlobalFilter
+	case c.GlobalFilter != settings.GlobalFilter:
+		log.Printf("Warning: Ignoring global filter %q in %q, using %q", settings.GlobalFilter, filename, c.GlobalFilter)
+	}
+	if settings.UseFilenameAsScheduledDate {
+		c.FilenameDate = true
+		c.FilenameDateFolders = append(c.FilenameDateFolders, settings.FilenameAsDateFolders...)
+	}
+	for _, status := range append(settings.StatusSettings.CoreStatuses, settings.StatusSettings.CustomStatuses...) {
+		typ, ok := tasksPluginStatusTypes[status.Type]
+		if !ok || len([]rune(status.Symbol)) != 1 {
+			continue
+		}
+		if c.Statuses == nil {
+			c.Statuses = make(map[string]obsast.PlugTasksStatusType)
+		}
+		c.Statuses[status.Symbol] = typ
+	}
+}
+
+// addRoot adds directory path (or directory of file path) to roots.
+func (c *tasksConfig) addRoot(path string) {
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+	if !slices.Contains(c.roots, root) {
+		c.roots = append(c.roots, root)
+	}
+}
+
+// sameSettings reports whether c and other have same settings, which are
+// saved in task cache (compiled formats and roots are not compared).
+func (c tasksConfig) sameSettings(other tasksConfig) bool {
+	c.filenameDateFormats, c.roots = nil, nil
+	other.filenameDateFormats, other.roots = nil, nil
+	return reflect.DeepEqual(c, other)
+}
+
+// findVault returns Obsidian vault directory containing path or empty string.
+func findVault(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".obsidian")); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// apply changes status type of the task according to configured statuses and
+// makes it not a task if it has NON_TASK status or does not contain global filter.
+func (c *tasksConfig) apply(task *Task) {
+	if !task.IsTask {
+		return
+	}
+	if m := reTaskCheckbox.FindStringSubmatch(task.Line); m != nil {
+		if typ, ok := c.Statuses[m[1]]; ok {
+			task.StatusType = typ
+		}
+	}
+	if task.StatusType == obsast.PlugTasksStatusTypeNonTask ||
+		(c.GlobalFilter != "" && !strings.Contains(task.Line, c.GlobalFilter)) {
+		task.IsTask, task.StatusType = false, 0
+	}
+}
+
+// filenameDate returns date in the filename if c.FilenameDate is enabled for it
+// or zero time. FilenameDateFolders are matched against path of the file relative
+// to the deepest root containing it, like Obsidian Tasks plugin does for vault.
+func (c *tasksConfig) filenameDate(filename string) time.Time {
+	if !c.FilenameDate || filename == "" {
+		return time.Time{}
+	}
+	path := c.relPath(filename)
+	inFolder := func(folder string) bool {
+		folder = strings.Trim(filepath.ToSlash(folder), "/")
+		return folder == "" || strings.HasPrefix(path, folder+"/")
+	}
+	if len(c.FilenameDateFolders) > 0 && !slices.ContainsFunc(c.FilenameDateFolders, inFolder) {
+		return time.Time{}
+	}
+	return c.dateInFilename(filename)
+}
+
+// relPath returns slash-separated path of the file relative to the deepest
+// root containing it or filename as is if there is no such root.
+func (c *tasksConfig) relPath(filename string) string {
+	path := filename
+	if abs, err := filepath.Abs(filename); err == nil {
+		found := false
+		for _, root := range c.roots {
+			rel, err := filepath.Rel(root, abs)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			if !found || len(rel) < len(path) {
+				path, found = rel, true
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// dateInFilename returns date in name of the file in one of c.FilenameDateFormats
+// (default defaultFilenameDateFormats) or zero time.
+func (c *tasksConfig) dateInFilename(filename string) time.Time {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	formats := c.filenameDateFormats
+	if len(formats) == 0 {
+		formats = defaultFilenameDateFormats
+	}
+	for _, format := range formats {
+		for _, m := range format.re.FindAllStringSubmatch(name, -1) {
+			if date, err := time.Parse(format.layout, m[1]); err == nil {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// newFilenameDateFormat compiles format of date in filename (with YYYY, MM and DD).
+func newFilenameDateFormat(format string) filenameDateFormat {
+	var expr, layout strings.Builder
+	for format != "" {
+		switch {
+		case strings.HasPrefix(format, "YYYY"):
+			expr.WriteString(`\d{4}`)
+			layout.WriteString("2006")
+			format = format[4:]
+		case strings.HasPrefix(format, "MM"):
+			expr.WriteString(`\d{2}`)
+			layout.WriteString("01")
+			format = format[2:]
+		case strings.HasPrefix(format, "DD"):
+			expr.WriteString(`\d{2}`)
+			layout.WriteString("02")
+			format = format[2:]
+		default:
+			expr.WriteString(regexp.QuoteMeta(format[:1]))
+			layout.WriteString(format[:1])
+			format = format[1:]
+		}
+	}
+	return filenameDateFormat{
+		re:     regexp.MustCompile(`(?:^|\D)(` + expr.String() + `)(?:$|\D)`),
+		layout: layout.String(),
+	}
+}
+
+// setFilenameDate returns tasks with scheduled date of tasks without due, scheduled
+// and start dates set to date in the filename (see filenameDate), like Obsidian
+// Tasks plugin does. Given tasks are not modified.
+func (c *tasksConfig) setFilenameDate(filename string, tasks []parsedTask) []parsedTask {
+	date := c.filenameDate(filename)
+	if date.IsZero() {
+		return tasks
+	}
+	tasks = slices.Clone(tasks)
+	for i := range tasks {
+		t := &tasks[i].Task
+		if t.Due.IsZero() && t.Scheduled.IsZero() && t.Start.IsZero() {
+			t.Scheduled = date
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadTasksConfig(t *testing.T) {
+	vault := t.TempDir()
+	settings := filepath.Join(vault, filepath.FromSlash(tasksPluginConfig))
+	err := os.MkdirAll(filepath.Dir(settings), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(settings, []byte(`{
+		"globalFilter": "#task",
+		"statusSettings": {
+			"coreStatuses": [{"symbol": " ", "type": "TODO"}, {"symbol": "x", "type": "DONE"}],
+			"customStatuses": [
+				{"symbol": "!", "type": "TODO"},
+				{"symbol": "?", "type": "NON_TASK"},
+				{"symbol": ">", "type": "CANCELLED"},
+				{"symbol": "b", "type": "IN_PROGRESS"}
+			]
+		}
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := filepath.Join(vault, "notes")
+	err = os.Mkdir(notes, 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg tasksConfig
+	cfg.load([]string{notes})
+	if cfg.GlobalFilter != "#task" || len(cfg.Statuses) != 6 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	if problems := lintTask(&cfg, Task{Line: "[!] Important"}, "!"); len(problems) != 0 {
+		t.Errorf("lintTask() = %q, want no problems with custom status", problems)
+	}
+
+	input := []byte(`- [ ] Todo #task 📅 2024-01-15
+- [ ] Not a task without global filter 📅 2024-01-15
+- [!] Important #task 📅 2024-01-15
+- [?] Question #task 📅 2024-01-15
+- [>] Forwarded #task 📅 2024-01-15
+- [b] Bookmark #task 📅 2024-01-15
+`)
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err = filterActualTasks(&filterOptions{Now: now, Config: config{Tasks: cfg}}, nil, "", input, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- [ ] Todo #task 📅 2024-01-15
+- [!] Important #task 📅 2024-01-15
+- [b] Bookmark #task 📅 2024-01-15
+`
+	if buf.String() != want {
+		t.Errorf("filterActualTasks() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestFilenameDate(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		cfg      tasksConfig
+		filename string
+		want     time.Time
+	}{
+		{"Disabled", tasksConfig{}, "Daily/2024-01-15.md", time.Time{}},
+		{"Default format", tasksConfig{FilenameDate: true}, "Daily/2024-01-15.md", date},
+		{"Compact format", tasksConfig{FilenameDate: true}, "/vault/Journal/20240115 Monday.md", date},
+		{"Date inside name", tasksConfig{FilenameDate: true}, "Meeting 2024-01-15 notes.md", date},
+		{"No date", tasksConfig{FilenameDate: true}, "Daily/Index.md", time.Time{}},
+		{"Longer number", tasksConfig{FilenameDate: true}, "Daily/202401150.md", time.Time{}},
+		{"Invalid date", tasksConfig{FilenameDate: true}, "Daily/2024-13-15.md", time.Time{}},
+		{"Date in folder", tasksConfig{FilenameDate: true}, "2024-01-15/notes.md", time.Time{}},
+		{
+			"In folder", tasksConfig{FilenameDate: true, FilenameDateFolders: []string{"Daily/"}, roots: []string{"/vault"}},
+			"/vault/Daily/2024-01-15.md", date,
+		},
+		{
+			"In folder of nested root", tasksConfig{
+				FilenameDate: true, FilenameDateFolders: []string{"Daily"}, roots: []string{"/vault", "/vault/Notes"},
+			},
+			"/vault/Notes/Daily/2024-01-15.md", date,
+		},
+		{
+			"Folder name outside of root", tasksConfig{
+				FilenameDate: true, FilenameDateFolders: []string{"Daily"}, roots: []string{"/vault"},
+			},
+			"/Daily/vault/2024-01-15.md", time.Time{},
+		},
+		{
+			"Same folder name deeper in root", tasksConfig{
+				FilenameDate: true, FilenameDateFolders: []string{"Daily"}, roots: []string{"/vault"},
+			},
+			"/vault/Projects/Daily/2024-01-15.md", time.Time{},
+		},
+		{
+			"In subfolder", tasksConfig{FilenameDate: true, FilenameDateFolders: []string{"Daily"}},
+			"Daily/2024/2024-01-15.md", date,
+		},
+		{
+			"Not in folder", tasksConfig{FilenameDate: true, FilenameDateFolders: []string{"Daily"}},
+			"Projects/2024-01-15.md", time.Time{},
+		},
+		{
+			"Custom format", tasksConfig{FilenameDate: true, FilenameDateFormats: []string{"DD.MM.YYYY"}},
+			"15.01.2024.md", date,
+		},
+		{
+			"Custom format only", tasksConfig{FilenameDate: true, FilenameDateFormats: []string{"DD.MM.YYYY"}},
+			"2024-01-15.md", time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.FilenameDateFormats = nil
+			for _, format := range tt.cfg.FilenameDateFormats {
+				err := cfg.addFilenameDateFormat(format)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cfg.filenameDate(filepath.FromSlash(tt.filename)); !got.Equal(tt.want) {
+				t.Errorf("filenameDate(%q) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilenameDateTasks(t *testing.T) {
+	files := map[string][]byte{
+		filepath.FromSlash("Daily/2024-01-15.md"): []byte(`- [ ] Call client
+- [ ] Submit report 📅 2024-01-20
+- [ ] Started 🛫 2024-01-10
+- [x] Done
+`),
+		filepath.FromSlash("Daily/2024-01-16.md"): []byte("- [ ] Tomorrow\n"),
+		filepath.FromSlash("Notes.md"):            []byte("- [ ] Undated\n"),
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	opts := &filterOptions{Now: now, Config: config{Tasks: tasksConfig{FilenameDate: true}}}
+	tasks, _, err := filterMarkdownFiles(opts, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{filepath.FromSlash("Daily/2024-01-15.md"): "- [ ] Call client\n"}
+	if len(tasks) != len(want) {
+		t.Errorf("filterMarkdownFiles() = %q, want %q", tasks, want)
+	}
+	for filename, content := range want {
+		if string(tasks[filename]) != content {
+			t.Errorf("filterMarkdownFiles()[%q] = %q, want %q", filename, tasks[filename], content)
+		}
+	}
+}
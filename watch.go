@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/yuin/goldmark"
+)
+
+const (
+	defaultWatchDebounce = 2 * time.Second
+	watchDayInterval     = time.Minute // How often to check if today was changed.
+	overdueHeader        = "Overdue:\n\n"
+)
+
+// watcher keeps parsed tasks of Markdown files in memory and finds tasks
+// which entered the window or became overdue since the previous check.
+type watcher struct {
+	opts     filterOptions                                       // Now, FromDay and ToDay are set by check.
+	emailTo  string                                              // Default address for notifications (empty for stdout).
+	window   func(now time.Time) (fromDay, toDay int, err error) // Window for the day of now.
+	md       goldmark.Markdown                                   // Parser reused for all files.
+	files    map[string]parsedFile                               // Filename -> tasks.
+	inWindow map[string]bool                                     // Tasks in the window at previous check.
+	overdue  map[string]bool                                     // Overdue tasks at previous check.
+	checked  bool                                                // Check was called at least once.
+	workdays *Calendar                                           // If not nil, tasks are checked only on working days.
+}
+
+func newWatcher(opts filterOptions, emailTo string, window func(now time.Time) (int, int, error)) *watcher {
+	return &watcher{
+		opts:     opts,
+		emailTo:  emailTo,
+		window:   window,
+		md:       newMarkdown(),
+		files:    make(map[string]parsedFile),
+		inWindow: make(map[string]bool),
+		overdue:  make(map[string]bool),
+	}
+}
+
+// update parses tasks of the file like parseFile or forgets the file if data is nil.
+func (w *watcher) update(filename string, data []byte) error {
+	if data == nil {
+		delete(w.files, filename)
+		return nil
+	}
+	read := func(string) ([]byte, error) { return data, nil }
+	file, err := parseFile(w.md, &w.opts.Config.Tasks, filename, read, nil)
+	if err != nil {
+		return fmt.Errorf("parse %q: %w", filename, err)
+	}
+	w.files[filename] = file
+	return nil
+}
+
+// check returns tasks which entered the window since previous check and tasks
+// which became overdue (due date before today) since previous check, in the same
+// format as output without -watch, for each address (see routeOutput).
+// First check returns all tasks in the window. On non-working days (if w.workdays
+// is set) it returns nothing, so changes are returned on next working day.
+func (w *watcher) check(now time.Time) (map[string]*bytes.Buffer, error) {
+	if w.workdays != nil && !w.workdays.IsWorkday(now) {
+		return nil, nil
+	}
+	opts := w.opts
+	var err error
+	opts.FromDay, opts.ToDay, err = w.window(now)
+	if err != nil {
+		return nil, err
+	}
+	opts.Now = now
+	index := indexTasks(w.files)
+	today := dateOf(now)
+	isOverdue := func(task Task) bool { return isOpen(task) && !task.Due.IsZero() && task.Due.Before(today) }
+
+	inWindow, overdue := make(map[string]bool), make(map[string]bool)
+	entered, blocked, overdueTasks := make(map[string][]byte), make(map[string][]byte), make(map[string][]byte)
+	recipients := make(map[string][]string)
+	for filename, file := range w.files {
+		if file.Settings.Skip {
+			continue
+		}
+		if w.emailTo != "" {
+			recipients[filename] = file.Settings.Recipients
+		}
+		var enteredBuf, blockedBuf, overdueBuf bytes.Buffer
+		r := opts.renderer(index, filename, file.Settings)
+		if opts.ShowBlocked {
+			r.Blocked = &blockedBuf
+		}
+		r.Filter = newTaskFilter(filename, w.inWindow, inWindow, true)
+		overdueR := opts.renderer(index, filename, file.Settings)
+		overdueR.ExpandRecurring = false
+		overdueR.Match = isOverdue
+		overdueR.Filter = newTaskFilter(filename, w.overdue, overdue, w.checked)
+		for _, t := range file.Tasks {
+			r.render(&enteredBuf, t.Task, t.Line)
+			overdueR.render(&overdueBuf, t.Task, t.Line)
+		}
+		if enteredBuf.Len() > 0 {
+			entered[filename] = enteredBuf.Bytes()
+		}
+		if blockedBuf.Len() > 0 {
+			blocked[filename] = blockedBuf.Bytes()
+		}
+		if overdueBuf.Len() > 0 {
+			overdueTasks[filename] = overdueBuf.Bytes()
+		}
+	}
+	w.inWindow, w.overdue, w.checked = inWindow, overdue, true
+
+	bufs := routeOutput(entered, blocked, recipients, w.emailTo)
+	for to, files := range routeTasks(overdueTasks, recipients, w.emailTo) {
+		buf := bufs[to]
+		if buf == nil {
+			buf = new(bytes.Buffer)
+			bufs[to] = buf
+		} else {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(overdueHeader)
+		overdueBuf := formatTasks(files)
+		buf.Write(overdueBuf.Bytes())
+	}
+	return bufs, nil
+}
+
+// newTaskFilter returns FilteredTasksRenderer.Filter which adds keys of all tasks
+// of the file to current and accepts tasks which were not in previous if accept is true.
+// Keys do not depend on task status and dates (see taskSummary), and identical
+// tasks in the file are numbered in order of rendering.
+func newTaskFilter(filename string, previous, current map[string]bool, accept bool) func(Task, bool) bool {
+	count := make(map[string]int)
+	return func(task Task, blocked bool) bool {
+		key := fmt.Sprintf("%s\x00%t\x00%s", filename, blocked, taskSummary(task))
+		count[key]++
+		key = fmt.Sprintf("%s\x00%d", key, count[key])
+		current[key] = true
+		return accept && !previous[key]
+	}
+}
+
+// runWatch outputs tasks in the window using notify and then watches paths for
+// changes of Markdown files and for change of today to notify about tasks
+// which entered the window or became overdue, until ctx is done.
+// Changed files are re-read after debounce since the last change.
+// Notifications queued by notify after a failure are sent by retry, which
+// returns time of its next call (zero if nothing is queued).
+func runWatch(
+	ctx context.Context, w *watcher, paths []string, debounce time.Duration, now func() time.Time,
+	notify func(map[string]*bytes.Buffer) error, retry func() (next time.Time, err error),
+) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	defer fsw.Close() //nolint:errcheck // Nothing to do.
+	ff := &w.opts.Config.Files
+
+	var dirs []*watchedDir // Watched directories.
+	var files []string     // Watched files.
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dir := newWatchedDir(absPath, ff)
+			dirs = append(dirs, dir)
+			_, err = dir.watch(fsw, absPath, ff)
+		} else if ff.isIncludedFile(absPath) {
+			files = append(files, absPath)
+			err = fsw.Add(filepath.Dir(absPath))
+		}
+		if err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+	}
+	dirOf := func(name string, isDir bool) *watchedDir { // Returns watched dir containing not ignored name.
+		for _, dir := range dirs {
+			if dir.contains(name, isDir) {
+				return dir
+			}
+		}
+		return nil
+	}
+	isWatched := func(name string) bool {
+		if slices.Contains(files, name) {
+			return true
+		}
+		dir := dirOf(name, false)
+		if dir == nil {
+			return false
+		}
+		rel, err := filepath.Rel(dir.path, name)
+		return err == nil && ff.isIncluded(filepath.ToSlash(rel))
+	}
+
+	read := func(filename string) ([]byte, error) { return readMarkdownFile(filename, w.opts.MaxFileSize) }
+	filenames, err := ff.listMarkdownFiles(paths)
+	if err != nil {
+		return err
+	}
+	w.files, err = parseFiles(&w.opts.Config.Tasks, filenames, read, w.opts.Cache)
+	if err != nil {
+		return err
+	}
+	retryTimer := time.NewTimer(0)
+	retryTimer.Stop()
+	defer retryTimer.Stop()
+	scheduleRetry := func() {
+		next, err := retry()
+		switch {
+		case err != nil:
+			log.Println("Warning: Failed to retry notifications:", err)
+		case !next.IsZero():
+			retryTimer.Reset(time.Until(next))
+		}
+	}
+	check := func() error {
+		bufs, err := w.check(now())
+		if err != nil {
+			return err
+		}
+		if len(bufs) > 0 {
+			err = notify(bufs)
+			if err != nil {
+				log.Println("Warning: Failed to notify:", err)
+			}
+		}
+		scheduleRetry()
+		return nil
+	}
+	err = check()
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	ticker := time.NewTicker(watchDayInterval)
+	defer ticker.Stop()
+	today := dateOf(now())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if dir := dirOf(ev.Name, true); dir != nil {
+						newFiles, err := dir.watch(fsw, ev.Name, ff)
+						if err != nil {
+							log.Println("Warning: Failed to watch:", err)
+						}
+						for _, filename := range newFiles {
+							changed[filename] = true
+						}
+						timer.Reset(debounce)
+					}
+				}
+			}
+			if isWatched(ev.Name) {
+				changed[ev.Name] = true
+				timer.Reset(debounce)
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("Warning: Watch:", err)
+		case <-timer.C:
+			for filename := range changed {
+				source, err := read(filename)
+				switch {
+				case errors.Is(err, fs.ErrNotExist):
+					source, err = nil, nil
+				case errors.Is(err, errFileTooLarge):
+					log.Println("Warning: Skipping", err)
+					source, err = nil, nil
+				}
+				if err == nil {
+					err = w.update(filename, source)
+				}
+				if err != nil {
+					log.Println("Warning: Failed to read changed file:", err)
+				}
+			}
+			clear(changed)
+			err = check()
+			if err != nil {
+				return err
+			}
+		case <-retryTimer.C:
+			scheduleRetry()
+		case <-ticker.C:
+			if day := dateOf(now()); !day.Equal(today) {
+				today = day
+				err = check()
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// watchedDir is a directory given by user with ignore rules for it.
+type watchedDir struct {
+	path string       // Absolute path.
+	root string       // Absolute path of rules root, see fileFilter.dirIgnoreRules.
+	ir   *ignoreRules // Built once, so ignore files changed while watching are not reloaded.
+}
+
+func newWatchedDir(path string, ff *fileFilter) *watchedDir {
+	ir, root := ff.dirIgnoreRules(path)
+	return &watchedDir{path: path, root: root, ir: ir}
+}
+
+// contains reports whether file or directory name (absolute path) is inside
+// the directory and is not ignored.
+func (d *watchedDir) contains(name string, isDir bool) bool {
+	if !strings.HasPrefix(name, d.path+string(filepath.Separator)) {
+		return false
+	}
+	rel, err := filepath.Rel(d.root, name)
+	return err == nil && !d.ir.ignored(filepath.ToSlash(rel), isDir)
+}
+
+// watch adds dir (absolute path of the directory or a not ignored directory
+// inside it) and all its not ignored subdirectories to fsw and returns
+// absolute paths of Markdown files in them selected by ff.
+func (d *watchedDir) watch(fsw *fsnotify.Watcher, dir string, ff *fileFilter) ([]string, error) {
+	rel, err := filepath.Rel(d.root, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = d.ir.walkDir(filepath.ToSlash(rel), func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(d.root, filepath.FromSlash(path))
+		if de.IsDir() {
+			return fsw.Add(name)
+		}
+		if ff.isIncluded(d.ir.relDir(path)) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
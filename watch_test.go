@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatcherCheck(t *testing.T) {
+	const file = "/notes/a.md"
+	window := func(time.Time) (int, int, error) { return 0, 1, nil }
+	w := newWatcher(filterOptions{ExpandRecurring: true}, "", window)
+	update := func(t *testing.T, source string) {
+		t.Helper()
+		err := w.update(file, []byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		source string
+		want   string
+	}{
+		{
+			name: "initial",
+			now:  now,
+			source: `- [ ] Today 📅 2024-01-15
+- [ ] Later 📅 2024-01-20
+- [ ] Overdue 📅 2024-01-10
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+- [ ] Blocker 🆔 a
+`,
+			want: file + `:
+- [ ] Today 📅 2024-01-15
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Daily 🔁 every day 📅 2024-01-15 (upcoming 2024-01-16)
+`,
+		},
+		{
+			name: "no changes",
+			now:  now,
+			source: `- [ ] Today 📅 2024-01-15
+- [ ] Later 📅 2024-01-20
+- [ ] Overdue 📅 2024-01-10
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+- [ ] Blocker 🆔 a
+`,
+			want: "",
+		},
+		{
+			name: "changed",
+			now:  now,
+			source: `- [ ] New line above
+- [/] Today 📅 2024-01-15
+- [ ] Later 📅 2024-01-16
+- [ ] Overdue 📅 2024-01-10
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+- [x] Blocker 🆔 a
+- [ ] Due yesterday 📅 2024-01-14
+`,
+			want: file + `:
+- [ ] Later 📅 2024-01-16
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+
+` + overdueHeader + file + `:
+- [ ] Due yesterday 📅 2024-01-14
+`,
+		},
+		{
+			name: "next day",
+			now:  now.AddDate(0, 0, 1),
+			source: `- [/] Today 📅 2024-01-15
+- [ ] Later 📅 2024-01-16
+- [ ] Overdue 📅 2024-01-10
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+- [x] Blocker 🆔 a
+- [ ] Due yesterday 📅 2024-01-14
+`,
+			want: file + `:
+- [ ] Daily 🔁 every day 📅 2024-01-15 (upcoming 2024-01-17)
+
+` + overdueHeader + file + `:
+- [/] Today 📅 2024-01-15
+- [ ] Daily 🔁 every day 📅 2024-01-15
+- [ ] Blocked 📅 2024-01-15 ⛔ a
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			update(t, tc.source)
+			bufs, err := w.check(tc.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if buf := bufs[""]; buf != nil {
+				got = buf.String()
+			}
+			if got != tc.want || len(bufs) > 1 {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	err := w.update(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bufs, err := w.check(now); err != nil || len(bufs) != 0 {
+		t.Errorf("removed file: %q, %v", bufs, err)
+	}
+}
+
+func TestRunWatch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(t *testing.T, name, source string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), []byte(source), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, "a.md", "- [ ] First 📅 2024-01-15\n")
+	write(t, "other.txt", "- [ ] Not Markdown 📅 2024-01-15\n")
+	write(t, "big.md", "- [ ] Too large 📅 2024-01-15\n"+strings.Repeat("text\n", 100))
+	write(t, ".gitignore", "drafts/\n")
+
+	now := func() time.Time { return time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC) }
+	w := newWatcher(filterOptions{MaxFileSize: 100}, "", func(time.Time) (int, int, error) { return 0, 0, nil })
+	var mu sync.Mutex
+	var notified []string
+	notify := func(bufs map[string]*bytes.Buffer) error {
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, bufs[""].String())
+		return nil
+	}
+	waitFor := func(t *testing.T, n int) []string {
+		t.Helper()
+		for range 200 {
+			mu.Lock()
+			got := append([]string(nil), notified...)
+			mu.Unlock()
+			if len(got) >= n {
+				return got
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("no notification %d", n)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	var retries atomic.Int32
+	retry := func() (time.Time, error) { // Queued notifications are sent on third retry.
+		if retries.Add(1) < 3 {
+			return time.Now().Add(10 * time.Millisecond), nil
+		}
+		return time.Time{}, nil
+	}
+	go func() { done <- runWatch(ctx, w, []string{dir}, 50*time.Millisecond, now, notify, retry) }()
+
+	got := waitFor(t, 1)
+	if want := filepath.Join(dir, "a.md") + ":\n- [ ] First 📅 2024-01-15\n"; got[0] != want {
+		t.Errorf("initial:\n%s\nwant:\n%s", got[0], want)
+	}
+	for i := 0; retries.Load() < 3; i++ {
+		if i == 200 {
+			t.Fatalf("retried %d times, want 3", retries.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	write(t, "other.txt", "- [ ] Still not Markdown 📅 2024-01-15\n")
+	write(t, "a.md", "- [ ] First 📅 2024-01-15\n- [ ] Second 📅 2024-01-15\n")
+	write(t, "sub/b.md", "- [ ] Third 📅 2024-01-15\n")
+	write(t, "sub/drafts/c.md", "- [ ] Draft 📅 2024-01-15\n")
+	write(t, "big.md", "- [ ] Still too large 📅 2024-01-15\n"+strings.Repeat("text\n", 100))
+	got = waitFor(t, 2)
+	if !strings.Contains(got[1], "- [ ] Second 📅 2024-01-15\n") || !strings.Contains(got[1], "- [ ] Third 📅 2024-01-15\n") ||
+		strings.Contains(got[1], "First") || strings.Contains(got[1], "Markdown") ||
+		strings.Contains(got[1], "Draft") || strings.Contains(got[1], "large") {
+		t.Errorf("changed:\n%s", got[1])
+	}
+
+	write(t, "sub/b.md", "- [ ] Third 📅 2024-01-15\n- [ ] Fourth 📅 2024-01-15\n")
+	got = waitFor(t, 3)
+	if want := filepath.Join(dir, "sub", "b.md") + ":\n- [ ] Fourth 📅 2024-01-15\n"; got[2] != want {
+		t.Errorf("new dir:\n%s\nwant:\n%s", got[2], want)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherCheckOptions(t *testing.T) {
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	window := func(time.Time) (int, int, error) { return 0, 0, nil }
+	check := func(t *testing.T, w *watcher, files map[string]string) map[string]string {
+		t.Helper()
+		for filename, source := range files {
+			err := w.update(filename, []byte(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		bufs, err := w.check(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for to, buf := range bufs {
+			got[to] = buf.String()
+		}
+		return got
+	}
+
+	t.Run("Show blocked", func(t *testing.T) {
+		w := newWatcher(filterOptions{ShowBlocked: true}, "", window)
+		got := check(t, w, map[string]string{"a.md": "- [ ] Blocked 📅 2024-01-15 ⛔ a\n- [ ] Blocker 🆔 a\n"})
+		want := blockedHeader + "a.md:\n- [ ] Blocked 📅 2024-01-15 ⛔ a\n    ⛔ [ ] Blocker 🆔 a (a.md)\n"
+		if got[""] != want {
+			t.Errorf("blocked:\n%s\nwant:\n%s", got[""], want)
+		}
+		got = check(t, w, map[string]string{"a.md": "- [ ] Blocked 📅 2024-01-15 ⛔ a\n- [x] Blocker 🆔 a\n"})
+		if want := "a.md:\n- [ ] Blocked 📅 2024-01-15 ⛔ a\n"; got[""] != want {
+			t.Errorf("unblocked:\n%s\nwant:\n%s", got[""], want)
+		}
+	})
+
+	t.Run("Identical lines", func(t *testing.T) {
+		w := newWatcher(filterOptions{}, "", window)
+		got := check(t, w, map[string]string{"a.md": "- [ ] Call 📅 2024-01-15\n- [ ] Call 📅 2024-01-15\n"})
+		if want := "a.md:\n- [ ] Call 📅 2024-01-15\n- [ ] Call 📅 2024-01-15\n"; got[""] != want {
+			t.Errorf("initial:\n%s\nwant:\n%s", got[""], want)
+		}
+		got = check(t, w, map[string]string{"a.md": "- [ ] Call 📅 2024-01-15\n- [ ] Call 📅 2024-01-15\n- [ ] Call 📅 2024-01-15\n"})
+		if want := "a.md:\n- [ ] Call 📅 2024-01-15\n"; got[""] != want {
+			t.Errorf("added:\n%s\nwant:\n%s", got[""], want)
+		}
+	})
+
+	t.Run("Note settings", func(t *testing.T) {
+		w := newWatcher(filterOptions{}, "me@example.com", window)
+		got := check(t, w, map[string]string{
+			"project.md": "---\ntasks-notify:\n  recipients: [alice@example.com]\n---\n- [ ] Project 📅 2024-01-15\n",
+			"skipped.md": "---\ntasks-notify:\n  skip: true\n---\n- [ ] Skipped 📅 2024-01-15\n",
+			"other.md":   "- [ ] Other 📅 2024-01-15\n",
+		})
+		want := map[string]string{
+			"alice@example.com": "project.md:\n- [ ] Project 📅 2024-01-15\n",
+			"me@example.com":    "other.md:\n- [ ] Other 📅 2024-01-15\n",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("check() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Workdays only", func(t *testing.T) {
+		w := newWatcher(filterOptions{}, "", window)
+		w.workdays = &Calendar{}
+		err := w.update("a.md", []byte("- [ ] Monday 📅 2024-01-15\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		saturday := time.Date(2024, 1, 13, 9, 0, 0, 0, time.UTC)
+		if bufs, err := w.check(saturday); err != nil || len(bufs) != 0 {
+			t.Errorf("saturday: %q, %v", bufs, err)
+		}
+		got := check(t, w, nil)
+		if want := "a.md:\n- [ ] Monday 📅 2024-01-15\n"; got[""] != want {
+			t.Errorf("monday:\n%s\nwant:\n%s", got[""], want)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errBadWindow  = errors.New("bad window")
+	errBadWeekday = errors.New("bad weekday")
+
+	reWindowRange    = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:\.\.(\d{4}-\d{2}-\d{2}))?$`)
+	reWindowNextDays = regexp.MustCompile(`^next (\d+) (business |work )?days?$`)
+)
+
+// dateOf returns midnight UTC of t's date in t's location.
+// Task dates have no timezone and are parsed as UTC, so they are comparable with result.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween returns amount of days from date a to date b (both from dateOf).
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Round(time.Hour).Hours() / 24)
+}
+
+// parseWeekday parses full or 3-letter English weekday name.
+func parseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(name)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", errBadWeekday, name)
+}
+
+// parseWindow converts window specification into inclusive range of days relative to today.
+//
+// Supported specifications:
+//   - today, tomorrow, yesterday (next and previous working day if cal is not nil)
+//   - this-week, next-week, last-week (week starts at weekStart)
+//   - this-month, next-month, last-month
+//   - next N days, next N business days (N days starting with today)
+//   - until WEEKDAY (from today to nearest WEEKDAY, including today)
+//   - YYYY-MM-DD, YYYY-MM-DD..YYYY-MM-DD
+//
+// Words may be separated by spaces or hyphens.
+func parseWindow(spec string, now time.Time, weekStart time.Weekday, cal *Calendar) (fromDay, toDay int, err error) {
+	today := dateOf(now)
+	business := cal != nil
+	if cal == nil {
+		cal = &Calendar{}
+	}
+	spec = strings.ToLower(strings.TrimSpace(spec))
+
+	if m := reWindowRange.FindStringSubmatch(spec); m != nil {
+		from, err := time.Parse(time.DateOnly, m[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w %q: %w", errBadWindow, spec, err)
+		}
+		to := from
+		if m[2] != "" {
+			to, err = time.Parse(time.DateOnly, m[2])
+			if err != nil {
+				return 0, 0, fmt.Errorf("%w %q: %w", errBadWindow, spec, err)
+			}
+		}
+		fromDay, toDay = daysBetween(today, from), daysBetween(today, to)
+		if fromDay > toDay {
+			return 0, 0, fmt.Errorf("%w %q: end is before start", errBadWindow, spec)
+		}
+		return fromDay, toDay, nil
+	}
+
+	spec = strings.Join(strings.Fields(strings.ReplaceAll(spec, "-", " ")), " ")
+	weekOffset := -((int(today.Weekday()) - int(weekStart) + 7) % 7)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthOffset := func(months int) (int, int) {
+		start := monthStart.AddDate(0, months, 0)
+		return daysBetween(today, start), daysBetween(today, start.AddDate(0, 1, -1))
+	}
+
+	switch spec {
+	case "today":
+		return 0, 0, nil
+	case "tomorrow":
+		if business {
+			fromDay, toDay = cal.BusinessWindow(now, 1, 1)
+			return fromDay, toDay, nil
+		}
+		return 1, 1, nil
+	case "yesterday":
+		if business {
+			fromDay, toDay = cal.BusinessWindow(now, -1, -1)
+			return fromDay, toDay, nil
+		}
+		return -1, -1, nil
+	case "this week":
+		return weekOffset, weekOffset + 6, nil
+	case "next week":
+		return weekOffset + 7, weekOffset + 13, nil
+	case "last week":
+		return weekOffset - 7, weekOffset - 1, nil
+	case "this month":
+		fromDay, toDay = monthOffset(0)
+		return fromDay, toDay, nil
+	case "next month":
+		fromDay, toDay = monthOffset(1)
+		return fromDay, toDay, nil
+	case "last month":
+		fromDay, toDay = monthOffset(-1)
+		return fromDay, toDay, nil
+	}
+
+	if weekday, ok := strings.CutPrefix(spec, "until "); ok {
+		d, err := parseWeekday(weekday)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w %q: %w", errBadWindow, spec, err)
+		}
+		return 0, (int(d) - int(today.Weekday()) + 7) % 7, nil
+	}
+
+	if m := reWindowNextDays.FindStringSubmatch(spec); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("%w %q: amount of days must be positive", errBadWindow, spec)
+		}
+		if m[2] == "" {
+			return 0, n - 1, nil
+		}
+		day := -1
+		for nonWorkdays := 0; n > 0 && nonWorkdays < maxNonWorkdays; {
+			day++
+			if cal.IsWorkday(today.AddDate(0, 0, day)) {
+				n--
+				nonWorkdays = 0
+			} else {
+				nonWorkdays++
+			}
+		}
+		return 0, day, nil
+	}
+
+	return 0, 0, fmt.Errorf("%w %q", errBadWindow, spec)
+}
+
+// resolveWindow returns inclusive range of days relative to today from window specification
+// (if not empty) or from fromDay and toDay.
+// If cal is not nil then fromDay and toDay are counted in working days and the range is
+// extended over following non-working days.
+func resolveWindow(now time.Time, spec string, weekStart time.Weekday, fromDay, toDay int, cal *Calendar) (
+	int, int, error,
+) {
+	switch {
+	case spec != "":
+		var err error
+		fromDay, toDay, err = parseWindow(spec, now, weekStart, cal)
+		if err != nil {
+			return 0, 0, err
+		}
+	case cal != nil:
+		fromDay, toDay = cal.BusinessWindow(now, fromDay, toDay)
+	}
+	if cal != nil {
+		toDay = cal.ExtendToWorkday(now, toDay)
+	}
+	return fromDay, toDay, nil
+}
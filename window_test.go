@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	wednesday := time.Date(2024, 1, 17, 23, 30, 0, 0, time.Local)
+	friday := time.Date(2024, 1, 19, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		spec      string
+		now       time.Time
+		weekStart time.Weekday
+		cal       *Calendar
+		wantFrom  int
+		wantTo    int
+		wantErr   bool
+	}{
+		{spec: "today", now: wednesday, wantFrom: 0, wantTo: 0},
+		{spec: "Tomorrow", now: wednesday, wantFrom: 1, wantTo: 1},
+		{spec: "yesterday", now: wednesday, wantFrom: -1, wantTo: -1},
+		{spec: "tomorrow", now: friday, wantFrom: 1, wantTo: 1},
+		{spec: "tomorrow", now: friday, cal: &Calendar{}, wantFrom: 3, wantTo: 3},
+		{spec: "yesterday", now: friday.AddDate(0, 0, 3), cal: &Calendar{}, wantFrom: -3, wantTo: -3},
+		{spec: "this-week", now: wednesday, weekStart: time.Monday, wantFrom: -2, wantTo: 4},
+		{spec: "this week", now: wednesday, weekStart: time.Sunday, wantFrom: -3, wantTo: 3},
+		{spec: "this-week", now: wednesday, weekStart: time.Wednesday, wantFrom: 0, wantTo: 6},
+		{spec: "this-week", now: wednesday, weekStart: time.Thursday, wantFrom: -6, wantTo: 0},
+		{spec: "next-week", now: wednesday, weekStart: time.Monday, wantFrom: 5, wantTo: 11},
+		{spec: "last-week", now: wednesday, weekStart: time.Monday, wantFrom: -9, wantTo: -3},
+		{spec: "this-month", now: wednesday, wantFrom: -16, wantTo: 14},
+		{spec: "next-month", now: wednesday, wantFrom: 15, wantTo: 43},
+		{spec: "last-month", now: wednesday, wantFrom: -47, wantTo: -17},
+		{spec: "next 3 days", now: wednesday, wantFrom: 0, wantTo: 2},
+		{spec: "next-1-day", now: wednesday, wantFrom: 0, wantTo: 0},
+		{spec: "next 3 business days", now: wednesday, wantFrom: 0, wantTo: 2},
+		{spec: "next 3 business days", now: friday, wantFrom: 0, wantTo: 4},
+		{spec: "next 3 business days", now: friday, cal: &Calendar{Holidays: map[time.Time]bool{dateOf(friday).AddDate(0, 0, 3): true}}, wantFrom: 0, wantTo: 5},
+		{spec: "next 0 days", now: wednesday, wantErr: true},
+		{spec: "until friday", now: wednesday, wantFrom: 0, wantTo: 2},
+		{spec: "until-Wed", now: wednesday, wantFrom: 0, wantTo: 0},
+		{spec: "until tuesday", now: wednesday, wantFrom: 0, wantTo: 6},
+		{spec: "until someday", now: wednesday, wantErr: true},
+		{spec: "2024-01-15..2024-01-21", now: wednesday, wantFrom: -2, wantTo: 4},
+		{spec: "2024-03-01", now: wednesday, wantFrom: 44, wantTo: 44},
+		{spec: "2024-01-21..2024-01-15", now: wednesday, wantErr: true},
+		{spec: "2024-02-30", now: wednesday, wantErr: true},
+		{spec: "soon", now: wednesday, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			from, to, err := parseWindow(tt.spec, tt.now, tt.weekStart, tt.cal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseWindow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if from != tt.wantFrom || to != tt.wantTo {
+				t.Errorf("parseWindow() = %d, %d, want %d, %d", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    time.Weekday
+		wantErr bool
+	}{
+		{"monday", time.Monday, false},
+		{"Sun", time.Sunday, false},
+		{"SATURDAY", time.Saturday, false},
+		{"mo", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWeekday(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseWeekday() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveWindow(t *testing.T) {
+	friday := time.Date(2024, 1, 19, 9, 0, 0, 0, time.Local)
+	monday := time.Date(2024, 1, 22, 9, 0, 0, 0, time.Local)
+	holidays := &Calendar{Holidays: map[time.Time]bool{dateOf(monday): true}}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		spec     string
+		fromDay  int
+		toDay    int
+		cal      *Calendar
+		wantFrom int
+		wantTo   int
+	}{
+		{name: "Calendar days", now: friday, fromDay: 0, toDay: 1, wantFrom: 0, wantTo: 1},
+		{name: "Today on Friday", now: friday, cal: &Calendar{}, wantFrom: 0, wantTo: 2},
+		{name: "Tomorrow on Friday", now: friday, fromDay: 1, toDay: 1, cal: &Calendar{}, wantFrom: 3, wantTo: 3},
+		{name: "Today on Friday before holiday", now: friday, cal: holidays, wantFrom: 0, wantTo: 3},
+		{name: "Yesterday on Monday", now: monday, fromDay: -1, cal: &Calendar{}, wantFrom: -3, wantTo: 0},
+		{name: "Window", now: friday, spec: "today", cal: &Calendar{}, wantFrom: 0, wantTo: 2},
+		{name: "Window with calendar days", now: friday, spec: "today", wantFrom: 0, wantTo: 0},
+		{name: "Window week", now: friday, spec: "this week", cal: holidays, wantFrom: -4, wantTo: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := resolveWindow(tt.now, tt.spec, time.Monday, tt.fromDay, tt.toDay, tt.cal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != tt.wantFrom || to != tt.wantTo {
+				t.Errorf("resolveWindow() = %d, %d, want %d, %d", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}